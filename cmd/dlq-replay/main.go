@@ -0,0 +1,40 @@
+// dlq-replay отправляет события из топика необработанных (geo-match-search-dlq)
+// обратно в исходные топики для тех групп потребителей, которые не смогли их обработать.
+// Запускается вручную после исправления причины ошибок.
+package main
+
+import (
+	"flag"
+	"geo_match_bot/internal/config"
+	"geo_match_bot/internal/messaging"
+	"log"
+	"time"
+)
+
+func main() {
+	groupID := flag.String("group", "dlq_replay", "группа потребителей; уже повторенные сообщения группа не читает снова")
+	idle := flag.Duration("idle", 10*time.Second, "завершить работу, если новых сообщений нет дольше этого времени")
+	flag.Parse()
+
+	// Загружаем конфигурацию
+	cfg := config.LoadConfig()
+
+	producer, err := messaging.NewKafkaProducer(cfg.KafkaBroker, cfg.KafkaFlushTimeout)
+	if err != nil {
+		log.Fatalf("Failed to initialize Kafka producer: %v", err)
+	}
+	defer producer.Close()
+
+	// Новая группа читает топик с начала
+	subscriber, err := messaging.NewKafkaSubscriber(cfg.KafkaBroker, *groupID, "earliest")
+	if err != nil {
+		log.Fatalf("Failed to initialize Kafka consumer: %v", err)
+	}
+	defer subscriber.Close()
+
+	replayed, err := messaging.ReplayDeadLetters(subscriber, producer, *idle)
+	log.Printf("Replayed %d dead-lettered events", replayed)
+	if err != nil {
+		log.Printf("Replay stopped: %v", err)
+	}
+}
@@ -1,14 +1,23 @@
 package main
 
 import (
+	"context"
+	"geo_match_bot/internal/app"
 	"geo_match_bot/internal/bot"
 	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/carousel"
 	"geo_match_bot/internal/config"
 	"geo_match_bot/internal/db"
+	"geo_match_bot/internal/gazetteer"
+	"geo_match_bot/internal/geo"
 	"geo_match_bot/internal/handlers"
 	"geo_match_bot/internal/messaging"
 	"geo_match_bot/internal/repository"
+	"geo_match_bot/internal/search"
 	"log"
+	"os/signal"
+	"sync"
+	"syscall"
 )
 
 func main() {
@@ -29,11 +38,14 @@ func main() {
 
 	// Инициализация Redis
 	redisClient := cache.NewRedisClient(cfg.RedisHost, cfg.RedisPort)
+	if len(cfg.RedisCluster) > 0 {
+		redisClient = cache.NewRedisClusterClient(cfg.RedisCluster)
+	}
 
-	// Инициализация Kafka Producer
-	kafkaProducer, err := messaging.NewKafkaProducer(cfg.KafkaBroker)
+	// Шина сообщений: Kafka или очередь в памяти процесса
+	bus, newSubscriber, err := app.NewMessageBus(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize Kafka producer: %v", err)
+		log.Fatalf("Failed to initialize message bus: %v", err)
 	}
 
 	// Инициализация Telegram бота
@@ -42,19 +54,142 @@ func main() {
 		log.Fatalf("Failed to create Telegram Bot: %v", err)
 	}
 
+	// Лимиты запросов на общение
+	connectLimiter := cache.NewConnectLimiter(redisClient, cfg)
+
+	// Гео-индекс видимых пользователей
+	geoIndex := app.NewGeoIndex(cfg, dbConn, redisClient)
+
 	// Создание репозитория пользователей
 	userRepo := repository.NewUserRepository(dbConn.Conn)
 
-	// Инициализация Kafka Consumer с Redis и Telegram ботом
-	kafkaConsumer, err := messaging.NewKafkaConsumer(cfg.KafkaBroker, "search_group", redisClient, telegramBot, userRepo)
+	// Репозиторий точных локаций (используется только при заданном сроке хранения)
+	locationRepo := repository.NewLocationRepository(dbConn.Conn)
+
+	// Репозиторий сохраненных поисков
+	savedSearchRepo := repository.NewSavedSearchRepository(dbConn.Conn)
+
+	// Репозиторий блокировок
+	blockRepo := repository.NewBlockRepository(dbConn.Conn)
+
+	// Репозиторий отметок для взаимных симпатий
+	likeRepo := repository.NewLikeRepository(dbConn.Conn)
+
+	// Огрубление координат и округление расстояний
+	privacy := geo.NewPrivacy(cfg)
+	if err := privacy.Validate(); err != nil {
+		log.Fatalf("Invalid location privacy settings: %v", err)
+	}
+
+	// Политика радиуса поиска (радиус по умолчанию, шаг и максимум расширения)
+	radiusPolicy := search.NewRadiusPolicy(cfg)
+
+	// Показ результатов поиска одним сообщением с листанием
+	resultsCarousel := carousel.NewCarousel(telegramBot, userRepo, redisClient, privacy)
+
+	// Разбор событий Kafka (на время перехода принимает и старый формат CSV)
+	eventCodec := messaging.NewCodec(cfg.KafkaAcceptLegacyEvents)
+
+	// Повторы обработки событий; необработанные события уходят в DeadLetterTopic
+	retryPolicy := messaging.NewRetryPolicy(cfg)
+
+	// События пишутся в outbox и пересылаются в шину отдельным воркером
+	var publisher messaging.Publisher = bus
+	var outbox *messaging.Outbox
+	if cfg.OutboxEnabled {
+		outbox = messaging.NewOutbox(repository.NewOutboxRepository(dbConn.Conn), bus, cfg)
+		publisher = outbox
+	}
+
+	// Показ результатов поиска от воркеров
+	searchResultsSubscriber, err := newSubscriber("search_results_group")
+	if err != nil {
+		log.Fatalf("Failed to initialize search results consumer: %v", err)
+	}
+	searchResultsConsumer := messaging.NewSearchResultsConsumer(messaging.NewWorkerPool(searchResultsSubscriber, cfg.ConsumerWorkers, cfg.ConsumerCommitInterval), telegramBot, redisClient, resultsCarousel, eventCodec,
+		messaging.NewDeadLetterQueue(bus, "search_results_group"))
+
+	// Воркер поиска в том же процессе (без брокера или без отдельного search-worker)
+	var searchWorker *messaging.SearchWorker
+	if cfg.SearchWorkerEmbedded {
+		searchSubscriber, err := newSubscriber("search_group")
+		if err != nil {
+			log.Fatalf("Failed to initialize search worker: %v", err)
+		}
+		searchWorker = messaging.NewSearchWorker(messaging.NewWorkerPool(searchSubscriber, cfg.ConsumerWorkers, cfg.ConsumerCommitInterval), geoIndex, userRepo, radiusPolicy, blockRepo, bus, eventCodec,
+			retryPolicy, messaging.NewDeadLetterQueue(bus, "search_group"), messaging.NewEventGuard(redisClient, "search_group", cfg))
+	}
+
+	// Уведомления по сохраненным поискам (отдельная группа потребителей)
+	savedSearchSubscriber, err := newSubscriber("saved_search_group")
+	if err != nil {
+		log.Fatalf("Failed to initialize saved search consumer: %v", err)
+	}
+	savedSearchConsumer := messaging.NewSavedSearchConsumer(messaging.NewWorkerPool(savedSearchSubscriber, cfg.ConsumerWorkers, cfg.ConsumerCommitInterval), telegramBot, userRepo, savedSearchRepo, redisClient, privacy, cfg.SavedSearchCooldown, cfg.SavedSearchRepeatAfter, eventCodec,
+		retryPolicy, messaging.NewDeadLetterQueue(bus, "saved_search_group"), messaging.NewEventGuard(redisClient, "saved_search_group", cfg))
+
+	// Справочник мест для ввода местоположения текстом
+	places, err := loadGazetteer(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize Kafka consumer: %v", err)
+		log.Fatalf("Failed to load gazetteer: %v", err)
 	}
 
 	// Инициализация хендлеров (обработчики команд и сообщений)
-	updateHandler := handlers.NewUpdateHandler(telegramBot, userRepo, memcacheClient, redisClient, kafkaProducer)
+	updateHandler := handlers.NewUpdateHandler(telegramBot, dbConn.Conn, userRepo, memcacheClient, geoIndex, redisClient, publisher, radiusPolicy, privacy, locationRepo, cfg.LocationRawRetention, places, savedSearchRepo, cfg.SavedSearchLimit, blockRepo, likeRepo, connectLimiter, resultsCarousel)
+
+	// Очистка устаревших локаций из гео-индекса
+	locationSweeper := handlers.NewLocationSweeper(telegramBot, dbConn.Conn, userRepo, memcacheClient, geoIndex, redisClient, publisher, locationRepo, cfg.LocationSweepInterval, cfg.LiveLocationInterval, cfg.LocationMaxAge, cfg.LocationRawRetention)
+
+	// При остановке перестаем получать обновления и останавливаем потребителей событий
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down...")
+		telegramBot.StopReceivingUpdates()
+	}()
+
+	// Запуск бота и потребителей событий
+	var consumers sync.WaitGroup
+	runConsumer := func(name string, run func(ctx context.Context) error) {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			if err := run(ctx); err != nil {
+				log.Fatalf("%s stopped: %v", name, err)
+			}
+		}()
+	}
+	runConsumer("Search results consumer", searchResultsConsumer.Run)
+	if searchWorker != nil {
+		runConsumer("Search worker", searchWorker.Run) // Обработка запросов поиска
+	}
+	runConsumer("Saved search consumer", savedSearchConsumer.Run)
+	go locationSweeper.Run()
+	if outbox != nil {
+		go outbox.Run()
+	}
 
-	// Запуск бота и Kafka consumer
-	go kafkaConsumer.HandleSearchRequests() // Запуск Kafka потребителя для обработки запросов
 	bot.Start(telegramBot, updateHandler)
+
+	// Дожидаемся обработки прочитанных событий, затем отправляем оставшиеся
+	stop()
+	consumers.Wait()
+
+	if outbox != nil {
+		if err := outbox.Close(); err != nil {
+			log.Printf("Failed to relay outbox messages: %v", err)
+		}
+	}
+	if err := bus.Close(); err != nil {
+		log.Printf("Failed to flush message bus: %v", err)
+	}
+}
+
+// loadGazetteer загружает справочник мест из файла или встроенный
+func loadGazetteer(cfg *config.Config) (*gazetteer.Gazetteer, error) {
+	if cfg.GazetteerPath != "" {
+		return gazetteer.LoadFile(cfg.GazetteerPath)
+	}
+	return gazetteer.LoadDefault()
 }
@@ -0,0 +1,71 @@
+// search-worker обрабатывает запросы поиска из geo-match-search и отправляет результаты
+// в geo-match-search-results. С Telegram не работает: результаты показывает бот.
+package main
+
+import (
+	"context"
+	"geo_match_bot/internal/app"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/config"
+	"geo_match_bot/internal/db"
+	"geo_match_bot/internal/messaging"
+	"geo_match_bot/internal/repository"
+	"geo_match_bot/internal/search"
+	"log"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// Загружаем конфигурацию
+	cfg := config.LoadConfig()
+
+	// Инициализация базы данных
+	dbConn, err := db.NewPostgresDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to DB: %v", err)
+	}
+
+	// Инициализация Redis
+	redisClient := cache.NewRedisClient(cfg.RedisHost, cfg.RedisPort)
+	if len(cfg.RedisCluster) > 0 {
+		redisClient = cache.NewRedisClusterClient(cfg.RedisCluster)
+	}
+
+	// Шина сообщений
+	bus, newSubscriber, err := app.NewMessageBus(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize message bus: %v", err)
+	}
+
+	// Гео-индекс видимых пользователей
+	geoIndex := app.NewGeoIndex(cfg, dbConn, redisClient)
+
+	userRepo := repository.NewUserRepository(dbConn.Conn)
+	blockRepo := repository.NewBlockRepository(dbConn.Conn)
+
+	// Все воркеры работают в одной группе и делят партиции запросов поиска
+	subscriber, err := newSubscriber("search_group")
+	if err != nil {
+		log.Fatalf("Failed to initialize search worker: %v", err)
+	}
+	worker := messaging.NewSearchWorker(
+		messaging.NewWorkerPool(subscriber, cfg.ConsumerWorkers, cfg.ConsumerCommitInterval),
+		geoIndex, userRepo, search.NewRadiusPolicy(cfg), blockRepo, bus,
+		messaging.NewCodec(cfg.KafkaAcceptLegacyEvents),
+		messaging.NewRetryPolicy(cfg), messaging.NewDeadLetterQueue(bus, "search_group"),
+		messaging.NewEventGuard(redisClient, "search_group", cfg),
+	)
+
+	// При остановке дожидаемся обработки прочитанных запросов и отправки оставшихся результатов
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := worker.Run(ctx); err != nil {
+		log.Printf("Search worker stopped: %v", err)
+	}
+	log.Println("Shutting down...")
+
+	if err := bus.Close(); err != nil {
+		log.Printf("Failed to flush message bus: %v", err)
+	}
+}
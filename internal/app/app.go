@@ -0,0 +1,62 @@
+// Package app собирает компоненты, общие для бота и воркера поиска
+package app
+
+import (
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/config"
+	"geo_match_bot/internal/db"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/messaging"
+	"geo_match_bot/internal/repository"
+	"log"
+)
+
+// NewMessageBus выбирает реализацию шины сообщений по конфигурации.
+// Возвращает Publisher и функцию, создающую Subscriber для группы потребителей.
+func NewMessageBus(cfg *config.Config) (messaging.Publisher, func(groupID string) (messaging.Subscriber, error), error) {
+	switch cfg.MessageBus {
+	case "memory":
+		bus := messaging.NewMemoryBus()
+		return bus, func(groupID string) (messaging.Subscriber, error) {
+			return bus.Subscriber(groupID), nil
+		}, nil
+	case "kafka", "":
+		// Без нужных топиков с правильными настройками работать нельзя - падаем сразу
+		if err := messaging.EnsureTopics(cfg.KafkaBroker, messaging.Topics(cfg)); err != nil {
+			return nil, nil, err
+		}
+		producer, err := messaging.NewKafkaProducer(cfg.KafkaBroker, cfg.KafkaFlushTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return producer, func(groupID string) (messaging.Subscriber, error) {
+			// Старые запросы поиска и события видимости уже неактуальны
+			return messaging.NewKafkaSubscriber(cfg.KafkaBroker, groupID, "latest")
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown message bus %q", cfg.MessageBus)
+	}
+}
+
+// NewGeoIndex выбирает реализацию гео-индекса по конфигурации
+func NewGeoIndex(cfg *config.Config, dbConn *db.DB, redisClient *cache.RedisClient) geo.Index {
+	switch cfg.GeoBackend {
+	case "redis_sharded":
+		index := cache.NewShardedRedisGeoIndex(redisClient, cfg.GeoShardPrecision, cfg.SearchLocationMaxAge)
+		if err := index.MigrateFromUnsharded(); err != nil {
+			log.Printf("Failed to migrate locations to sharded geo index: %v", err)
+		}
+		return index
+	case "postgis":
+		return repository.NewPostGISIndex(dbConn.Conn, cfg.SearchLocationMaxAge)
+	case "memory":
+		return geo.NewMemoryIndex(cfg.SearchLocationMaxAge)
+	default:
+		index := cache.NewRedisGeoIndex(redisClient, cfg.SearchLocationMaxAge)
+		if err := index.MigrateLegacyKeys(); err != nil {
+			log.Printf("Failed to migrate geo index keys: %v", err)
+		}
+		return index
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"fmt"
+	"geo_match_bot/internal/config"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ConnectDenial - причина, по которой запрос на общение не может быть отправлен.
+// Значения возвращает reserveScript, поэтому порядок констант менять нельзя.
+type ConnectDenial int
+
+const (
+	ConnectAllowed    ConnectDenial = iota
+	DailyLimitReached               // Исчерпан дневной лимит запросов
+	CooldownActive                  // Запрос этому пользователю уже отправлялся недавно
+	TooManyPending                  // Слишком много запросов ждут ответа
+)
+
+// ConnectQuota - сколько запросов пользователь еще может отправить
+type ConnectQuota struct {
+	DailyLeft   int // Осталось запросов сегодня
+	DailyLimit  int
+	PendingLeft int // Сколько еще запросов может одновременно ждать ответа
+	MaxPending  int
+}
+
+// ConnectLimiter ограничивает запросы на общение: дневной лимит, интервал
+// перед повторным запросом тому же пользователю и число запросов без ответа.
+// Счетчики хранятся в Redis, дневной счетчик сбрасывается в полночь по UTC.
+type ConnectLimiter struct {
+	*RedisClient
+	dailyLimit int
+	cooldown   time.Duration
+	maxPending int
+	pendingTTL time.Duration // Запрос без ответа перестает учитываться через это время
+}
+
+// NewConnectLimiter создает ограничитель запросов с лимитами из конфигурации
+func NewConnectLimiter(redisClient *RedisClient, cfg *config.Config) *ConnectLimiter {
+	return &ConnectLimiter{
+		RedisClient: redisClient,
+		dailyLimit:  cfg.ConnectDailyLimit,
+		cooldown:    cfg.ConnectCooldown,
+		maxPending:  cfg.ConnectMaxPending,
+		pendingTTL:  cfg.ConnectPendingTTL,
+	}
+}
+
+// Все ключи отправителя лежат под одним hash tag, чтобы reserveScript работал в Redis Cluster
+func connectDailyKey(userID int64, now time.Time) string {
+	return fmt.Sprintf("connect:{%d}:requests:%s", userID, now.UTC().Format("2006-01-02"))
+}
+
+func connectCooldownKey(senderID, targetID int64) string {
+	return fmt.Sprintf("connect:{%d}:cooldown:%d", senderID, targetID)
+}
+
+func connectPendingKey(userID int64) string {
+	return fmt.Sprintf("connect:{%d}:pending", userID)
+}
+
+// reserveScript проверяет и учитывает запрос одной операцией, чтобы одновременные запросы
+// (например, двойное нажатие кнопки) не проходили мимо интервала и лимита ожидающих.
+// Возвращает {ConnectDenial, сколько осталось ждать в мс}.
+// KEYS: интервал перед повторным запросом, запросы без ответа, дневной счетчик.
+// ARGV: текущее unix-время, получатель, граница просроченных запросов, интервал (мс),
+// срок запроса без ответа (мс), лимит ожидающих, дневной лимит.
+var reserveScript = redis.NewScript(`
+local wait = redis.call("PTTL", KEYS[1])
+if wait > 0 then
+	return {2, wait}
+end
+
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", ARGV[3])
+local maxPending = tonumber(ARGV[6])
+if maxPending > 0 and redis.call("ZCARD", KEYS[2]) >= maxPending then
+	return {3, 0}
+end
+
+local dailyLimit = tonumber(ARGV[7])
+if dailyLimit > 0 then
+	local count = redis.call("INCR", KEYS[3])
+	redis.call("EXPIRE", KEYS[3], 172800)
+	if count > dailyLimit then
+		redis.call("DECR", KEYS[3])
+		return {1, 0}
+	end
+end
+
+if tonumber(ARGV[4]) > 0 then
+	redis.call("SET", KEYS[1], 1, "PX", ARGV[4])
+end
+redis.call("ZADD", KEYS[2], ARGV[1], ARGV[2])
+redis.call("PEXPIRE", KEYS[2], ARGV[5])
+return {0, 0}
+`)
+
+// Reserve проверяет лимиты и, если запрос разрешен, учитывает его.
+// Для CooldownActive также возвращает, сколько осталось ждать.
+func (l *ConnectLimiter) Reserve(senderID, targetID int64) (ConnectDenial, time.Duration, error) {
+	now := time.Now()
+
+	keys := []string{connectCooldownKey(senderID, targetID), connectPendingKey(senderID), connectDailyKey(senderID, now)}
+	result, err := reserveScript.Run(l.ctx, l.client, keys,
+		now.Unix(),
+		targetID,
+		now.Add(-l.pendingTTL).Unix(),
+		l.cooldown.Milliseconds(),
+		l.pendingTTL.Milliseconds(),
+		l.maxPending,
+		l.dailyLimit,
+	).Int64Slice()
+	if err != nil {
+		return ConnectAllowed, 0, err
+	}
+	if len(result) != 2 {
+		return ConnectAllowed, 0, fmt.Errorf("unexpected reserve script result %v", result)
+	}
+
+	return ConnectDenial(result[0]), time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// Resolve снимает запрос из ожидающих ответа (принят, отклонен или пользователи заблокированы)
+func (l *ConnectLimiter) Resolve(senderID, targetID int64) error {
+	return l.client.ZRem(l.ctx, connectPendingKey(senderID), strconv.FormatInt(targetID, 10)).Err()
+}
+
+// Quota возвращает оставшиеся запросы пользователя
+func (l *ConnectLimiter) Quota(userID int64) (ConnectQuota, error) {
+	now := time.Now()
+
+	used, err := l.client.Get(l.ctx, connectDailyKey(userID, now)).Int()
+	if err != nil && err != redis.Nil {
+		return ConnectQuota{}, err
+	}
+	pending, err := l.pendingCount(userID, now)
+	if err != nil {
+		return ConnectQuota{}, err
+	}
+
+	return ConnectQuota{
+		DailyLeft:   max(l.dailyLimit-used, 0),
+		DailyLimit:  l.dailyLimit,
+		PendingLeft: max(l.maxPending-pending, 0),
+		MaxPending:  l.maxPending,
+	}, nil
+}
+
+// pendingCount удаляет просроченные запросы без ответа и возвращает число оставшихся
+func (l *ConnectLimiter) pendingCount(userID int64, now time.Time) (int, error) {
+	key := connectPendingKey(userID)
+	var count *redis.IntCmd
+	_, err := l.client.Pipelined(l.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(l.ctx, key, "-inf", strconv.FormatInt(now.Add(-l.pendingTTL).Unix(), 10))
+		count = pipe.ZCard(l.ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	liveUntilKey    = "live_locations"          // Sorted set: userID -> unix-время окончания трансляции
+	liveMessagesKey = "live_locations:messages" // Hash: userID -> ID сообщения с трансляцией
+)
+
+// StartLiveLocation запоминает активную трансляцию геопозиции пользователя
+func (r *RedisClient) StartLiveLocation(userID int64, messageID int, until time.Time) error {
+	member := strconv.FormatInt(userID, 10)
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(r.ctx, liveUntilKey, &redis.Z{Score: float64(until.Unix()), Member: member})
+		pipe.HSet(r.ctx, liveMessagesKey, member, messageID)
+		return nil
+	})
+	return err
+}
+
+// GetLiveLocation возвращает ID сообщения и время окончания активной трансляции.
+// ok == false, если трансляции нет.
+func (r *RedisClient) GetLiveLocation(userID int64) (messageID int, until time.Time, ok bool, err error) {
+	member := strconv.FormatInt(userID, 10)
+	messageID, err = r.client.HGet(r.ctx, liveMessagesKey, member).Int()
+	if err == redis.Nil {
+		return 0, time.Time{}, false, nil
+	} else if err != nil {
+		return 0, time.Time{}, false, err
+	}
+
+	score, err := r.client.ZScore(r.ctx, liveUntilKey, member).Result()
+	if err == redis.Nil {
+		return 0, time.Time{}, false, nil
+	} else if err != nil {
+		return 0, time.Time{}, false, err
+	}
+
+	return messageID, time.Unix(int64(score), 0), true, nil
+}
+
+// StopLiveLocation забывает трансляцию пользователя.
+// Возвращает true, если трансляция была активна.
+func (r *RedisClient) StopLiveLocation(userID int64) (bool, error) {
+	member := strconv.FormatInt(userID, 10)
+	var removed *redis.IntCmd
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(r.ctx, liveUntilKey, member)
+		pipe.HDel(r.ctx, liveMessagesKey, member)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// ExpiredLiveLocations возвращает пользователей, чья трансляция закончилась к моменту now
+func (r *RedisClient) ExpiredLiveLocations(now time.Time) ([]int64, error) {
+	members, err := r.client.ZRangeByScore(r.ctx, liveUntilKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []int64
+	for _, member := range members {
+		userID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		expired = append(expired, userID)
+	}
+	return expired, nil
+}
@@ -48,6 +48,22 @@ func (c *MemcacheClient) Set(key string, value string) error {
 	return nil
 }
 
+// Add записывает значение, только если ключа еще нет.
+// Возвращает false, если ключ уже занят.
+func (c *MemcacheClient) Add(key string, value string) (bool, error) {
+	err := c.Client.Add(&memcache.Item{
+		Key:   key,
+		Value: []byte(value),
+	})
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (c *MemcacheClient) Delete(key string) error {
 	err := c.Client.Delete(key)
 	if err != nil {
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"fmt"
+	"geo_match_bot/internal/geo"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Оба ключа под одним hash tag, чтобы транзакции над ними работали в Redis Cluster
+const (
+	userLocationsKey = "user_locations:{global}"      // GeoSet с локациями пользователей
+	lastSeenKey      = "user_locations_seen:{global}" // Sorted set: userID -> unix-время последнего обновления локации
+)
+
+// Имена ключей до перехода на hash tag, данные из них переносит MigrateLegacyKeys
+const (
+	legacyUserLocationsKey = "user_locations"
+	legacyLastSeenKey      = "user_locations_seen"
+)
+
+// RedisGeoIndex - гео-индекс пользователей в Redis (GeoSet + время обновления локаций)
+type RedisGeoIndex struct {
+	*RedisClient
+	searchMaxAge time.Duration // Кандидаты с более старой локацией не попадают в поиск
+}
+
+// NewRedisGeoIndex создает гео-индекс поверх подключения к Redis
+func NewRedisGeoIndex(redisClient *RedisClient, searchMaxAge time.Duration) *RedisGeoIndex {
+	return &RedisGeoIndex{
+		RedisClient:  redisClient,
+		searchMaxAge: searchMaxAge,
+	}
+}
+
+// Add добавляет локацию пользователя в Redis (с использованием гео-функции)
+// и запоминает время ее обновления
+func (r *RedisGeoIndex) Add(userID int64, latitude, longitude float64) error {
+	member := strconv.FormatInt(userID, 10)
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.GeoAdd(r.ctx, userLocationsKey, &redis.GeoLocation{
+			Longitude: longitude,
+			Latitude:  latitude,
+			Name:      member,
+		})
+		pipe.ZAdd(r.ctx, lastSeenKey, &redis.Z{Score: float64(time.Now().Unix()), Member: member})
+		return nil
+	})
+
+	return err
+}
+
+// Nearby выполняет GEOSEARCH по гео-индексу: от пользователя или от координат,
+// по кругу или прямоугольнику, с сортировкой ASC и ограничением COUNT.
+// Пользователи, чья локация не обновлялась дольше searchMaxAge, пропускаются.
+func (r *RedisGeoIndex) Nearby(q geo.Query) ([]geo.Neighbor, error) {
+	query := redis.GeoSearchQuery{
+		Sort: "ASC",
+	}
+
+	if q.FromUserID != 0 {
+		query.Member = strconv.FormatInt(q.FromUserID, 10)
+	} else {
+		query.Latitude = q.Latitude
+		query.Longitude = q.Longitude
+	}
+
+	if q.IsBox() {
+		query.BoxWidth = q.WidthKm
+		query.BoxHeight = q.HeightKm
+		query.BoxUnit = "km"
+	} else {
+		query.Radius = q.RadiusKm
+		query.RadiusUnit = "km"
+	}
+
+	// Запрашиваем с запасом, чтобы после исключения самого пользователя и заблокированных осталось Limit результатов.
+	// Устаревшие локации отсеиваются уже после COUNT, поэтому, если их оказалось слишком много,
+	// повторяем запрос с вдвое большим COUNT, пока не наберется Limit результатов или не кончится радиус.
+	count := q.Limit + len(q.Excluded())
+	for {
+		if q.Limit > 0 {
+			query.Count = count
+		}
+
+		locations, err := r.client.GeoSearchLocation(r.ctx, userLocationsKey, &redis.GeoSearchLocationQuery{
+			GeoSearchQuery: query,
+			WithDist:       true,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		nearbyUsers, err := r.freshNeighbors(q, locations)
+		if err != nil {
+			return nil, err
+		}
+		if q.Limit <= 0 || len(nearbyUsers) >= q.Limit || len(locations) < count {
+			return nearbyUsers, nil
+		}
+		count *= 2
+	}
+}
+
+// freshNeighbors извлекает userID и расстояние из результата GEOSEARCH,
+// исключая самого себя, исключенных пользователей и устаревшие локации
+func (r *RedisGeoIndex) freshNeighbors(q geo.Query, locations []redis.GeoLocation) ([]geo.Neighbor, error) {
+	if len(locations) == 0 {
+		return nil, nil
+	}
+
+	// Получаем время последнего обновления локации для всех кандидатов одним запросом
+	members := make([]string, len(locations))
+	for i, location := range locations {
+		members[i] = location.Name
+	}
+	lastSeen, err := r.client.ZMScore(r.ctx, lastSeenKey, members...).Result()
+	if err != nil {
+		return nil, err
+	}
+	staleBefore := float64(time.Now().Add(-r.searchMaxAge).Unix())
+
+	var nearbyUsers []geo.Neighbor
+	for i, location := range locations {
+		nearbyID, err := strconv.ParseInt(location.Name, 10, 64)
+		if err != nil || q.Excludes(nearbyID) {
+			continue
+		}
+		if r.searchMaxAge > 0 && lastSeen[i] < staleBefore {
+			continue
+		}
+		nearbyUsers = append(nearbyUsers, geo.Neighbor{UserID: nearbyID, DistanceKm: location.Dist})
+		if q.Limit > 0 && len(nearbyUsers) == q.Limit {
+			break
+		}
+	}
+	return nearbyUsers, nil
+}
+
+// Position возвращает сохраненную локацию пользователя
+func (r *RedisGeoIndex) Position(userID int64) (float64, float64, error) {
+	location, err := r.client.GeoPos(r.ctx, userLocationsKey, strconv.FormatInt(userID, 10)).Result()
+	if err != nil || len(location) == 0 || location[0] == nil {
+		return 0, 0, fmt.Errorf("location not found or error: %v", err)
+	}
+
+	return location[0].Latitude, location[0].Longitude, nil
+}
+
+// Remove удаляет пользователя из GeoSet и из набора времени обновления
+func (r *RedisGeoIndex) Remove(userID int64) error {
+	member := strconv.FormatInt(userID, 10)
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(r.ctx, userLocationsKey, member)
+		pipe.ZRem(r.ctx, lastSeenKey, member)
+		return nil
+	})
+	return err
+}
+
+// MigrateLegacyKeys переименовывает ключи индекса, созданные до перехода на hash tag.
+// Старые ключи могли появиться только на одиночном Redis, где RENAME между ними возможен.
+func (r *RedisGeoIndex) MigrateLegacyKeys() error {
+	for legacyKey, key := range map[string]string{
+		legacyUserLocationsKey: userLocationsKey,
+		legacyLastSeenKey:      lastSeenKey,
+	} {
+		exists, err := r.client.Exists(r.ctx, legacyKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			continue
+		}
+		if err := r.client.RenameNX(r.ctx, legacyKey, key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillLastSeen проставляет текущее время обновления тем пользователям в GeoSet,
+// у которых его еще нет (локации, добавленные до появления lastSeenKey)
+func (r *RedisGeoIndex) BackfillLastSeen() error {
+	members, err := r.client.ZRange(r.ctx, userLocationsKey, 0, -1).Result()
+	if err != nil || len(members) == 0 {
+		return err
+	}
+
+	now := float64(time.Now().Unix())
+	entries := make([]*redis.Z, len(members))
+	for i, member := range members {
+		entries[i] = &redis.Z{Score: now, Member: member}
+	}
+	return r.client.ZAddNX(r.ctx, lastSeenKey, entries...).Err()
+}
+
+// RemoveStale удаляет из GeoSet пользователей, чья локация не обновлялась дольше maxAge,
+// и возвращает их ID
+func (r *RedisGeoIndex) RemoveStale(maxAge time.Duration) ([]int64, error) {
+	cutoff := time.Now().Add(-maxAge).Unix()
+	members, err := r.client.ZRangeByScore(r.ctx, lastSeenKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(cutoff, 10),
+	}).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	removable := make([]interface{}, len(members))
+	for i, member := range members {
+		removable[i] = member
+	}
+	_, err = r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(r.ctx, userLocationsKey, removable...)
+		pipe.ZRem(r.ctx, lastSeenKey, removable...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []int64
+	for _, member := range members {
+		userID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		removed = append(removed, userID)
+	}
+	return removed, nil
+}
@@ -0,0 +1,358 @@
+package cache
+
+import (
+	"fmt"
+	"geo_match_bot/internal/geo"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	shardLocationsPrefix = "user_locations"        // Префикс GeoSet шарда: user_locations:{geohash}
+	shardLastSeenPrefix  = "user_locations_seen"   // Префикс набора времени обновления шарда: user_locations_seen:{geohash}
+	shardMapKey          = "user_locations_shard"  // Hash: userID -> шард, в котором лежит его локация
+	shardListKey         = "user_locations_shards" // Set: все непустые шарды
+)
+
+// ShardedRedisGeoIndex - гео-индекс, разбитый на шарды по префиксу geohash.
+// Каждый шард - отдельная пара ключей (GeoSet и время обновления) с общим hash tag,
+// поэтому в Redis Cluster шарды распределяются по разным узлам.
+// Поиск опрашивает все шарды, пересекающие область запроса, и объединяет результаты по расстоянию.
+type ShardedRedisGeoIndex struct {
+	*RedisClient
+	precision    int           // Длина префикса geohash, задающего шард
+	searchMaxAge time.Duration // Кандидаты с более старой локацией не попадают в поиск
+}
+
+// NewShardedRedisGeoIndex создает шардированный гео-индекс поверх подключения к Redis
+func NewShardedRedisGeoIndex(redisClient *RedisClient, precision int, searchMaxAge time.Duration) *ShardedRedisGeoIndex {
+	return &ShardedRedisGeoIndex{
+		RedisClient:  redisClient,
+		precision:    precision,
+		searchMaxAge: searchMaxAge,
+	}
+}
+
+func shardLocationsKey(shard string) string {
+	return fmt.Sprintf("%s:{%s}", shardLocationsPrefix, shard)
+}
+
+func shardLastSeenKey(shard string) string {
+	return fmt.Sprintf("%s:{%s}", shardLastSeenPrefix, shard)
+}
+
+// Add добавляет локацию пользователя в шард его ячейки.
+// Если пользователь переместился в другую ячейку, он удаляется из старого шарда.
+func (r *ShardedRedisGeoIndex) Add(userID int64, latitude, longitude float64) error {
+	member := strconv.FormatInt(userID, 10)
+	shard := geo.EncodeGeohash(latitude, longitude, r.precision)
+
+	oldShard, err := r.client.HGet(r.ctx, shardMapKey, member).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if oldShard != "" && oldShard != shard {
+		if err := r.removeFromShard(oldShard, member); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.GeoAdd(r.ctx, shardLocationsKey(shard), &redis.GeoLocation{
+			Longitude: longitude,
+			Latitude:  latitude,
+			Name:      member,
+		})
+		pipe.ZAdd(r.ctx, shardLastSeenKey(shard), &redis.Z{Score: float64(time.Now().Unix()), Member: member})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(r.ctx, shardMapKey, member, shard)
+		pipe.SAdd(r.ctx, shardListKey, shard)
+		return nil
+	})
+	return err
+}
+
+// Remove удаляет пользователя из его шарда
+func (r *ShardedRedisGeoIndex) Remove(userID int64) error {
+	member := strconv.FormatInt(userID, 10)
+	shard, err := r.client.HGet(r.ctx, shardMapKey, member).Result()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := r.removeFromShard(shard, member); err != nil {
+		return err
+	}
+	return r.client.HDel(r.ctx, shardMapKey, member).Err()
+}
+
+// removeFromShard удаляет участника из GeoSet и набора времени обновления шарда
+func (r *ShardedRedisGeoIndex) removeFromShard(shard string, members ...interface{}) error {
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(r.ctx, shardLocationsKey(shard), members...)
+		pipe.ZRem(r.ctx, shardLastSeenKey(shard), members...)
+		return nil
+	})
+	return err
+}
+
+// Position возвращает сохраненную локацию пользователя
+func (r *ShardedRedisGeoIndex) Position(userID int64) (float64, float64, error) {
+	member := strconv.FormatInt(userID, 10)
+	shard, err := r.client.HGet(r.ctx, shardMapKey, member).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("location not found or error: %v", err)
+	}
+
+	location, err := r.client.GeoPos(r.ctx, shardLocationsKey(shard), member).Result()
+	if err != nil || len(location) == 0 || location[0] == nil {
+		return 0, 0, fmt.Errorf("location not found or error: %v", err)
+	}
+
+	return location[0].Latitude, location[0].Longitude, nil
+}
+
+// Nearby опрашивает шарды, покрывающие область запроса, одним пайплайном
+// и объединяет результаты по расстоянию
+func (r *ShardedRedisGeoIndex) Nearby(q geo.Query) ([]geo.Neighbor, error) {
+	latitude, longitude := q.Latitude, q.Longitude
+	if q.FromUserID != 0 {
+		var err error
+		latitude, longitude, err = r.Position(q.FromUserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := redis.GeoSearchQuery{
+		Latitude:  latitude,
+		Longitude: longitude,
+		Sort:      "ASC",
+	}
+	if q.IsBox() {
+		query.BoxWidth = q.WidthKm
+		query.BoxHeight = q.HeightKm
+		query.BoxUnit = "km"
+	} else {
+		query.Radius = q.RadiusKm
+		query.RadiusUnit = "km"
+	}
+
+	minLat, minLon, maxLat, maxLon := q.Bounds(latitude, longitude)
+	shards := geo.CoverGeohashes(minLat, minLon, maxLat, maxLon, r.precision)
+
+	// Как и в несшардированном индексе, устаревшие локации отсеиваются после COUNT.
+	// Шарды, в которых после отсева осталось меньше Limit результатов, а COUNT был исчерпан,
+	// опрашиваются повторно с вдвое большим COUNT.
+	counts := make([]int, len(shards))
+	for i := range counts {
+		counts[i] = q.Limit + len(q.Excluded())
+	}
+	found := make([][]geo.Neighbor, len(shards))
+	pending := make([]int, len(shards))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for len(pending) > 0 {
+		locations, err := r.searchShards(shards, pending, counts, query, q.Limit > 0)
+		if err != nil {
+			return nil, err
+		}
+		neighbors, err := r.freshNeighbors(q, shards, pending, locations)
+		if err != nil {
+			return nil, err
+		}
+
+		var truncated []int
+		for j, i := range pending {
+			found[i] = neighbors[j]
+			if q.Limit > 0 && len(neighbors[j]) < q.Limit && len(locations[j]) >= counts[i] {
+				counts[i] *= 2
+				truncated = append(truncated, i)
+			}
+		}
+		pending = truncated
+	}
+
+	var nearbyUsers []geo.Neighbor
+	for _, neighbors := range found {
+		nearbyUsers = append(nearbyUsers, neighbors...)
+	}
+	sort.Slice(nearbyUsers, func(i, j int) bool {
+		return nearbyUsers[i].DistanceKm < nearbyUsers[j].DistanceKm
+	})
+	if q.Limit > 0 && len(nearbyUsers) > q.Limit {
+		nearbyUsers = nearbyUsers[:q.Limit]
+	}
+
+	return nearbyUsers, nil
+}
+
+// searchShards выполняет GEOSEARCH по указанным шардам одним пайплайном,
+// для каждого шарда со своим COUNT
+func (r *ShardedRedisGeoIndex) searchShards(shards []string, pending, counts []int, query redis.GeoSearchQuery, limited bool) ([][]redis.GeoLocation, error) {
+	searches := make([]*redis.GeoSearchLocationCmd, len(pending))
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for j, i := range pending {
+			shardQuery := query
+			if limited {
+				shardQuery.Count = counts[i]
+			}
+			searches[j] = pipe.GeoSearchLocation(r.ctx, shardLocationsKey(shards[i]), &redis.GeoSearchLocationQuery{
+				GeoSearchQuery: shardQuery,
+				WithDist:       true,
+			})
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	locations := make([][]redis.GeoLocation, len(pending))
+	for j := range pending {
+		locations[j], _ = searches[j].Result()
+	}
+	return locations, nil
+}
+
+// freshNeighbors получает время обновления кандидатов всех шардов одним пайплайном
+// и отсеивает самого пользователя, исключенных и устаревшие локации
+func (r *ShardedRedisGeoIndex) freshNeighbors(q geo.Query, shards []string, pending []int, locations [][]redis.GeoLocation) ([][]geo.Neighbor, error) {
+	lastSeen := make([]*redis.FloatSliceCmd, len(pending))
+	_, err := r.client.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for j, i := range pending {
+			if len(locations[j]) == 0 {
+				continue
+			}
+			members := make([]string, len(locations[j]))
+			for k, location := range locations[j] {
+				members[k] = location.Name
+			}
+			lastSeen[j] = pipe.ZMScore(r.ctx, shardLastSeenKey(shards[i]), members...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	staleBefore := float64(time.Now().Add(-r.searchMaxAge).Unix())
+
+	neighbors := make([][]geo.Neighbor, len(pending))
+	for j := range pending {
+		if len(locations[j]) == 0 {
+			continue
+		}
+		scores := lastSeen[j].Val()
+		for k, location := range locations[j] {
+			nearbyID, err := strconv.ParseInt(location.Name, 10, 64)
+			if err != nil || q.Excludes(nearbyID) {
+				continue
+			}
+			if r.searchMaxAge > 0 && scores[k] < staleBefore {
+				continue
+			}
+			neighbors[j] = append(neighbors[j], geo.Neighbor{UserID: nearbyID, DistanceKm: location.Dist})
+			if q.Limit > 0 && len(neighbors[j]) == q.Limit {
+				break
+			}
+		}
+	}
+	return neighbors, nil
+}
+
+// RemoveStale удаляет из всех шардов пользователей, чья локация не обновлялась дольше maxAge
+func (r *ShardedRedisGeoIndex) RemoveStale(maxAge time.Duration) ([]int64, error) {
+	shards, err := r.client.SMembers(r.ctx, shardListKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := strconv.FormatInt(time.Now().Add(-maxAge).Unix(), 10)
+	var removed []int64
+	for _, shard := range shards {
+		members, err := r.client.ZRangeByScore(r.ctx, shardLastSeenKey(shard), &redis.ZRangeBy{
+			Min: "-inf",
+			Max: cutoff,
+		}).Result()
+		if err != nil {
+			return removed, err
+		}
+		if len(members) == 0 {
+			continue
+		}
+
+		removable := make([]interface{}, len(members))
+		for i, member := range members {
+			removable[i] = member
+		}
+		if err := r.removeFromShard(shard, removable...); err != nil {
+			return removed, err
+		}
+		if err := r.client.HDel(r.ctx, shardMapKey, members...).Err(); err != nil {
+			return removed, err
+		}
+
+		for _, member := range members {
+			userID, err := strconv.ParseInt(member, 10, 64)
+			if err != nil {
+				continue
+			}
+			removed = append(removed, userID)
+		}
+	}
+
+	return removed, nil
+}
+
+// MigrateFromUnsharded переносит локации из общих ключей несшардированного индекса
+// (и их старых имен без hash tag) в шарды и удаляет общие ключи.
+// Нужна один раз при переходе на шардированный индекс.
+func (r *ShardedRedisGeoIndex) MigrateFromUnsharded() error {
+	for _, locationsKey := range []string{legacyUserLocationsKey, userLocationsKey} {
+		members, err := r.client.ZRange(r.ctx, locationsKey, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			continue
+		}
+
+		positions, err := r.client.GeoPos(r.ctx, locationsKey, members...).Result()
+		if err != nil {
+			return err
+		}
+
+		for i, member := range members {
+			userID, err := strconv.ParseInt(member, 10, 64)
+			if err != nil || positions[i] == nil {
+				continue
+			}
+			if err := r.Add(userID, positions[i].Latitude, positions[i].Longitude); err != nil {
+				return err
+			}
+		}
+		log.Printf("Migrated %d locations from %s to sharded geo index", len(members), locationsKey)
+	}
+
+	// Ключи удаляются по одному: в Redis Cluster старые ключи лежат в разных слотах
+	for _, key := range []string{legacyUserLocationsKey, legacyLastSeenKey, userLocationsKey, lastSeenKey} {
+		if err := r.client.Del(r.ctx, key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"geo_match_bot/internal/geo"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func searchResultsKey(userID int64) string {
+	return fmt.Sprintf("search_results:%d", userID)
+}
+
+// SaveSearchResults сохраняет результаты последнего поиска пользователя для листания
+func (r *RedisClient) SaveSearchResults(userID int64, neighbors []geo.Neighbor, ttl time.Duration) error {
+	data, err := json.Marshal(neighbors)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(r.ctx, searchResultsKey(userID), data, ttl).Err()
+}
+
+// ReplaceSearchResults заменяет сохраненные результаты поиска, не продлевая их срок
+func (r *RedisClient) ReplaceSearchResults(userID int64, neighbors []geo.Neighbor) error {
+	data, err := json.Marshal(neighbors)
+	if err != nil {
+		return err
+	}
+	return r.client.SetXX(r.ctx, searchResultsKey(userID), data, redis.KeepTTL).Err()
+}
+
+// GetSearchResults возвращает результаты последнего поиска пользователя.
+// ok == false, если они не сохранялись или уже истекли.
+func (r *RedisClient) GetSearchResults(userID int64) ([]geo.Neighbor, bool, error) {
+	data, err := r.client.Get(r.ctx, searchResultsKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var neighbors []geo.Neighbor
+	if err := json.Unmarshal(data, &neighbors); err != nil {
+		return nil, false, err
+	}
+	return neighbors, true, nil
+}
+
+func searchRequestKey(userID int64) string {
+	return fmt.Sprintf("search_request:%d", userID)
+}
+
+// takeSearchRequestScript удаляет ID запроса, только если он совпадает с переданным
+var takeSearchRequestScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// SetSearchRequest запоминает ID последнего запроса поиска пользователя
+func (r *RedisClient) SetSearchRequest(userID int64, requestID string, ttl time.Duration) error {
+	return r.client.Set(r.ctx, searchRequestKey(userID), requestID, ttl).Err()
+}
+
+// TakeSearchRequest проверяет, что requestID - последний запрос поиска пользователя, и забывает его.
+// Возвращает false для запроса, который пользователь уже повторил, истекшего или уже обработанного.
+func (r *RedisClient) TakeSearchRequest(userID int64, requestID string) (bool, error) {
+	deleted, err := takeSearchRequestScript.Run(r.ctx, r.client, []string{searchRequestKey(userID)}, requestID).Int()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TryAcquire занимает ключ на время ttl (SET NX).
+// Возвращает false, если ключ уже занят, - действие недавно выполнялось.
+func (r *RedisClient) TryAcquire(key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(r.ctx, key, 1, ttl).Result()
+}
+
+// Release освобождает ключ, занятый TryAcquire, раньше окончания ttl
+func (r *RedisClient) Release(key string) error {
+	return r.client.Del(r.ctx, key).Err()
+}
+
+// advanceScript записывает время, только если оно не раньше сохраненного
+var advanceScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]))
+local next = tonumber(ARGV[1])
+if current and next < current then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// Advance сохраняет в ключе время at, если оно не раньше уже сохраненного.
+// Возвращает false, если сохранено более позднее время.
+func (r *RedisClient) Advance(key string, at time.Time, ttl time.Duration) (bool, error) {
+	advanced, err := advanceScript.Run(r.ctx, r.client, []string{key}, at.UnixMicro(), ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return advanced > 0, nil
+}
+
+// startScript занимает ключ аренды, если действие еще не завершено (нет ключа завершения)
+// и его не выполняет кто-то другой
+var startScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+if redis.call("SET", KEYS[2], 1, "NX", "PX", ARGV[1]) then
+	return 1
+end
+return 0
+`)
+
+// TryStart занимает leaseKey на время lease, если doneKey еще не записан.
+// Возвращает false, если действие уже завершено или выполняется.
+func (r *RedisClient) TryStart(doneKey, leaseKey string, lease time.Duration) (bool, error) {
+	started, err := startScript.Run(r.ctx, r.client, []string{doneKey, leaseKey}, lease.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return started > 0, nil
+}
+
+// Finish записывает doneKey на время ttl и освобождает leaseKey, занятый TryStart
+func (r *RedisClient) Finish(doneKey, leaseKey string, ttl time.Duration) error {
+	_, err := r.client.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(r.ctx, doneKey, 1, ttl)
+		pipe.Del(r.ctx, leaseKey)
+		return nil
+	})
+	return err
+}
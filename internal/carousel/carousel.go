@@ -0,0 +1,234 @@
+package carousel
+
+import (
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/repository"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	resultsTTL   = time.Hour // Сколько можно листать результаты поиска
+	maxBioLength = 600       // Подпись к фото ограничена 1024 символами
+)
+
+// Carousel показывает результаты поиска одним сообщением: фото с подписью и кнопками.
+// Листание редактирует это же сообщение, а не отправляет новые.
+type Carousel struct {
+	bot            *tgbotapi.BotAPI
+	userRepository *repository.UserRepository
+	redisClient    *cache.RedisClient // Результаты поиска для листания
+	privacy        geo.Privacy        // Округление показываемых расстояний
+}
+
+func NewCarousel(bot *tgbotapi.BotAPI, userRepo *repository.UserRepository, redisClient *cache.RedisClient, privacy geo.Privacy) *Carousel {
+	return &Carousel{
+		bot:            bot,
+		userRepository: userRepo,
+		redisClient:    redisClient,
+		privacy:        privacy,
+	}
+}
+
+// Show сохраняет результаты поиска и отправляет карточку первого (ближайшего) пользователя
+func (c *Carousel) Show(telegramID int64, neighbors []geo.Neighbor) {
+	if err := c.redisClient.SaveSearchResults(telegramID, neighbors, resultsTTL); err != nil {
+		log.Printf("Error saving search results: %v", err)
+	}
+
+	neighbors, index, user, err := c.pick(telegramID, neighbors, 0)
+	if err != nil {
+		log.Printf("Error building search card: %v", err)
+		c.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении данных пользователя."))
+		return
+	}
+	if user == nil {
+		c.bot.Send(tgbotapi.NewMessage(telegramID, "Результаты поиска устарели. Начните новый поиск: /search"))
+		return
+	}
+	photo, caption, keyboard := c.card(neighbors, index, user)
+
+	if photo != "" {
+		msg := tgbotapi.NewPhoto(telegramID, tgbotapi.FileID(photo))
+		msg.Caption = caption
+		msg.ReplyMarkup = keyboard
+		c.bot.Send(msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, caption)
+	msg.ReplyMarkup = keyboard
+	c.bot.Send(msg)
+}
+
+// Navigate показывает карточку с номером index в сообщении, на кнопку которого нажал пользователь
+func (c *Carousel) Navigate(callbackQuery *tgbotapi.CallbackQuery, index int) {
+	c.bot.Request(tgbotapi.NewCallback(callbackQuery.ID, ""))
+
+	message := callbackQuery.Message
+	telegramID := message.Chat.ID
+
+	neighbors, ok, err := c.redisClient.GetSearchResults(telegramID)
+	if err != nil {
+		log.Printf("Error getting search results: %v", err)
+	}
+	if !ok || len(neighbors) == 0 {
+		c.bot.Send(tgbotapi.NewMessage(telegramID, "Результаты поиска устарели. Начните новый поиск: /search"))
+		return
+	}
+	if index < 0 || index >= len(neighbors) {
+		index = 0
+	}
+
+	neighbors, index, user, err := c.pick(telegramID, neighbors, index)
+	if err != nil {
+		log.Printf("Error building search card: %v", err)
+		c.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении данных пользователя."))
+		return
+	}
+	if user == nil {
+		c.bot.Send(tgbotapi.NewMessage(telegramID, "Результаты поиска устарели. Начните новый поиск: /search"))
+		return
+	}
+	photo, caption, keyboard := c.card(neighbors, index, user)
+
+	hasPhoto := len(message.Photo) > 0
+	switch {
+	case photo != "" && hasPhoto && message.Photo[len(message.Photo)-1].FileID == photo:
+		// Фото то же самое - достаточно заменить подпись
+		edit := tgbotapi.NewEditMessageCaption(telegramID, message.MessageID, caption)
+		edit.ReplyMarkup = &keyboard
+		c.bot.Send(edit)
+	case photo != "" && hasPhoto:
+		media := tgbotapi.NewInputMediaPhoto(tgbotapi.FileID(photo))
+		media.Caption = caption
+		c.bot.Send(tgbotapi.EditMessageMediaConfig{
+			BaseEdit: tgbotapi.BaseEdit{
+				ChatID:      telegramID,
+				MessageID:   message.MessageID,
+				ReplyMarkup: &keyboard,
+			},
+			Media: media,
+		})
+	case photo == "" && !hasPhoto:
+		c.bot.Send(tgbotapi.NewEditMessageTextAndMarkup(telegramID, message.MessageID, caption, keyboard))
+	default:
+		// Сообщение с фото нельзя превратить в текстовое и наоборот - заменяем его
+		c.bot.Request(tgbotapi.NewDeleteMessage(telegramID, message.MessageID))
+		if photo != "" {
+			msg := tgbotapi.NewPhoto(telegramID, tgbotapi.FileID(photo))
+			msg.Caption = caption
+			msg.ReplyMarkup = keyboard
+			c.bot.Send(msg)
+		} else {
+			msg := tgbotapi.NewMessage(telegramID, caption)
+			msg.ReplyMarkup = keyboard
+			c.bot.Send(msg)
+		}
+	}
+}
+
+// Forget убирает пользователя из сохраненных результатов поиска, например после блокировки,
+// чтобы его карточка больше не попадалась при листании
+func (c *Carousel) Forget(telegramID, userID int64) {
+	neighbors, ok, err := c.redisClient.GetSearchResults(telegramID)
+	if err != nil {
+		log.Printf("Error getting search results: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	remaining := make([]geo.Neighbor, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		if neighbor.UserID != userID {
+			remaining = append(remaining, neighbor)
+		}
+	}
+	if len(remaining) == len(neighbors) {
+		return
+	}
+	if err := c.redisClient.ReplaceSearchResults(telegramID, remaining); err != nil {
+		log.Printf("Error updating search results: %v", err)
+	}
+}
+
+// pick находит пользователя для карточки с номером index. Пользователи, удаленные после поиска,
+// пропускаются и убираются из сохраненных результатов. Возвращает оставшиеся результаты,
+// номер карточки и пользователя; user == nil, если показывать больше некого.
+func (c *Carousel) pick(telegramID int64, neighbors []geo.Neighbor, index int) ([]geo.Neighbor, int, *repository.User, error) {
+	for len(neighbors) > 0 {
+		if index >= len(neighbors) {
+			index = len(neighbors) - 1
+		}
+
+		user, err := c.userRepository.GetUserByTelegramID(neighbors[index].UserID)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if user != nil {
+			return neighbors, index, user, nil
+		}
+
+		neighbors = append(neighbors[:index:index], neighbors[index+1:]...)
+		if err := c.redisClient.ReplaceSearchResults(telegramID, neighbors); err != nil {
+			log.Printf("Error updating search results: %v", err)
+		}
+	}
+	return nil, 0, nil, nil
+}
+
+// card собирает фото, подпись и кнопки карточки пользователя с номером index
+func (c *Carousel) card(neighbors []geo.Neighbor, index int, user *repository.User) (string, string, tgbotapi.InlineKeyboardMarkup) {
+	neighbor := neighbors[index]
+
+	photo, err := c.userRepository.GetUserPhoto(neighbor.UserID)
+	if err != nil {
+		photo = ""
+	}
+
+	caption := fmt.Sprintf("Имя: %s\nВозраст: %d\nПол: %s\nО себе: %s\nРасстояние: %s\n\n%d из %d",
+		user.FirstName, user.Age, user.Gender, truncate(user.Bio, maxBioLength), c.privacy.FormatDistance(neighbor.DistanceKm),
+		index+1, len(neighbors))
+
+	// Листание: назад, вперед, а на последней карточке - новый поиск
+	var navigation []tgbotapi.InlineKeyboardButton
+	if index > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", fmt.Sprintf("carousel_%d", index-1)))
+	}
+	if index < len(neighbors)-1 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("Вперед ▶️", fmt.Sprintf("carousel_%d", index+1)))
+	} else {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("🔄 Искать снова", "search_next"))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		navigation,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Предложить пообщаться", fmt.Sprintf("connect_%d", neighbor.UserID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❤️ Нравится", fmt.Sprintf("like_%d", neighbor.UserID)),
+			tgbotapi.NewInlineKeyboardButtonData("👎 Пропустить", fmt.Sprintf("pass_%d", neighbor.UserID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Заблокировать", fmt.Sprintf("block_%d", neighbor.UserID)),
+		),
+	)
+
+	return photo, caption, keyboard
+}
+
+// truncate обрезает текст до limit символов
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit]) + "…"
+}
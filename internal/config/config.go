@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,7 +20,74 @@ type Config struct {
 	MemcachePort  string
 	RedisHost     string
 	RedisPort     string
+	RedisCluster  []string // Адреса узлов Redis Cluster (если заданы, используются вместо RedisHost/RedisPort)
 	KafkaBroker   string
+	GeoBackend    string // redis, redis_sharded, postgis или memory
+
+	GeoShardPrecision int    // Длина префикса geohash для шардов (redis_sharded)
+	GazetteerPath     string // Файл справочника мест (если не задан, используется встроенный)
+
+	KafkaAcceptLegacyEvents bool          // Принимать события Kafka в старом формате CSV (на время перехода)
+	MessageBus              string        // kafka или memory (в одном процессе, без брокера)
+	KafkaFlushTimeout       time.Duration // Сколько ждать доставки оставшихся сообщений при остановке
+
+	// Топики Kafka: создаются при запуске, настройки существующих проверяются
+	KafkaTopicPartitions     int
+	KafkaReplicationFactor   int
+	KafkaTopicRetention      time.Duration // Сколько хранить события поиска и результаты
+	KafkaDeadLetterRetention time.Duration // Сколько хранить необработанные события
+
+	// Outbox: события пишутся в БД и пересылаются в шину отдельным воркером
+	OutboxEnabled       bool
+	OutboxRelayInterval time.Duration // Как часто проверять неотправленные события
+	OutboxBatchSize     int           // Сколько событий отправлять за одну транзакцию
+	OutboxRetention     time.Duration // Сколько хранить отправленные события
+	OutboxLease         time.Duration // На сколько воркер забирает события для отправки (дольше таймаута доставки)
+	OutboxMaxAttempts   int           // После стольких неудачных отправок событие откладывается
+
+	// Потребители событий
+	SearchWorkerEmbedded   bool          // Обрабатывать запросы поиска в процессе бота, а не в отдельном search-worker
+	ConsumerWorkers        int           // Сколько событий обрабатывается параллельно (события одного пользователя - по порядку)
+	ConsumerCommitInterval time.Duration // Как часто коммитить позиции обработанных событий
+
+	// Повторы обработки событий (после них событие уходит в топик необработанных)
+	EventMaxAttempts     int           // Сколько раз всего пытаться обработать событие
+	EventRetryBackoff    time.Duration // Задержка перед первым повтором, дальше удваивается
+	EventRetryMaxBackoff time.Duration // Максимальная задержка между повторами
+	EventDedupTTL        time.Duration // Сколько помнить обработанные события и время последнего примененного
+	EventProcessingLease time.Duration // Сколько другие потребители не берут событие, которое уже обрабатывается
+
+	// Параметры радиуса поиска
+	SearchDefaultRadiusKm float64 // Радиус по умолчанию, если пользователь не выбрал свой
+	SearchRadiusStepKm    float64 // Шаг расширения радиуса
+	SearchMaxRadiusKm     float64 // Максимальный радиус, до которого расширяется поиск
+	SearchMinResults      int     // Минимальное число кандидатов, при котором расширение прекращается
+	SearchResultLimit     int     // Максимальное число кандидатов, возвращаемых одним поиском
+
+	// Устаревание локаций
+	LocationMaxAge        time.Duration // Локации старше этого возраста удаляются из гео-индекса
+	LocationSweepInterval time.Duration // Как часто запускается очистка устаревших локаций
+	SearchLocationMaxAge  time.Duration // Кандидаты с более старой локацией не показываются в поиске
+	LiveLocationInterval  time.Duration // Как часто проверяется окончание трансляций геопозиции
+
+	// Приватность локаций
+	LocationPrivacyMode   string        // off, grid или jitter
+	LocationGridMeters    float64       // Размер ячейки сетки для режима grid
+	LocationJitterMeters  float64       // Максимальное смещение для режима jitter
+	LocationPrivacySecret string        // Ключ для вычисления смещения пользователя
+	DistanceFloorKm       float64       // Минимальное показываемое расстояние
+	LocationRawRetention  time.Duration // Сколько хранить точные координаты (0 - не хранить)
+
+	// Сохраненные поиски
+	SavedSearchLimit       int           // Сколько поисков может сохранить один пользователь
+	SavedSearchCooldown    time.Duration // Не чаще одного уведомления пользователю за этот интервал
+	SavedSearchRepeatAfter time.Duration // Через сколько можно снова уведомить об одном и том же пользователе
+
+	// Лимиты запросов на общение
+	ConnectDailyLimit int           // Сколько запросов можно отправить за сутки (0 - без ограничения)
+	ConnectCooldown   time.Duration // Через сколько можно повторить запрос тому же пользователю
+	ConnectMaxPending int           // Сколько запросов может одновременно ждать ответа (0 - без ограничения)
+	ConnectPendingTTL time.Duration // Через сколько запрос без ответа перестает учитываться
 }
 
 func LoadConfig() *Config {
@@ -26,7 +96,7 @@ func LoadConfig() *Config {
 		log.Fatal("Error loading .env file")
 	}
 
-	return &Config{
+	cfg := &Config{
 		TelegramToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
 		PostgresUser:  os.Getenv("POSTGRES_USER"),
 		PostgresPass:  os.Getenv("POSTGRES_PASSWORD"),
@@ -36,6 +106,164 @@ func LoadConfig() *Config {
 		MemcachePort:  os.Getenv("MEMCACHE_PORT"),
 		RedisHost:     os.Getenv("REDIS_HOST"),
 		RedisPort:     os.Getenv("REDIS_PORT"),
+		RedisCluster:  getEnvList("REDIS_CLUSTER_ADDRS"),
 		KafkaBroker:   os.Getenv("KAFKA_BROKER"),
+		GeoBackend:    getEnv("GEO_BACKEND", "redis"),
+
+		GeoShardPrecision: getEnvInt("GEO_SHARD_PRECISION", 3),
+		GazetteerPath:     os.Getenv("GAZETTEER_PATH"),
+
+		KafkaAcceptLegacyEvents: getEnvBool("KAFKA_ACCEPT_LEGACY_EVENTS", true),
+		MessageBus:              getEnv("MESSAGE_BUS", "kafka"),
+		KafkaFlushTimeout:       getEnvDuration("KAFKA_FLUSH_TIMEOUT", 15*time.Second),
+
+		KafkaTopicPartitions:     getEnvInt("KAFKA_TOPIC_PARTITIONS", 6),
+		KafkaReplicationFactor:   getEnvInt("KAFKA_REPLICATION_FACTOR", 1),
+		KafkaTopicRetention:      getEnvDuration("KAFKA_TOPIC_RETENTION", 7*24*time.Hour),
+		KafkaDeadLetterRetention: getEnvDuration("KAFKA_DLQ_RETENTION", 14*24*time.Hour),
+
+		OutboxEnabled:       getEnvBool("OUTBOX_ENABLED", true),
+		OutboxRelayInterval: getEnvDuration("OUTBOX_RELAY_INTERVAL", time.Second),
+		OutboxBatchSize:     getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetention:     getEnvDuration("OUTBOX_RETENTION", 24*time.Hour),
+		OutboxLease:         getEnvDuration("OUTBOX_LEASE", 10*time.Minute),
+		OutboxMaxAttempts:   getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+
+		SearchWorkerEmbedded:   getEnvBool("SEARCH_WORKER_EMBEDDED", true),
+		ConsumerWorkers:        getEnvInt("CONSUMER_WORKERS", 8),
+		ConsumerCommitInterval: getEnvDuration("CONSUMER_COMMIT_INTERVAL", time.Second),
+
+		EventMaxAttempts:     getEnvInt("EVENT_MAX_ATTEMPTS", 3),
+		EventRetryBackoff:    getEnvDuration("EVENT_RETRY_BACKOFF", 500*time.Millisecond),
+		EventRetryMaxBackoff: getEnvDuration("EVENT_RETRY_MAX_BACKOFF", 10*time.Second),
+		EventDedupTTL:        getEnvDuration("EVENT_DEDUP_TTL", 24*time.Hour),
+		EventProcessingLease: getEnvDuration("EVENT_PROCESSING_LEASE", time.Minute),
+
+		SearchDefaultRadiusKm: getEnvFloat("SEARCH_DEFAULT_RADIUS_KM", 5),
+		SearchRadiusStepKm:    getEnvFloat("SEARCH_RADIUS_STEP_KM", 5),
+		SearchMaxRadiusKm:     getEnvFloat("SEARCH_MAX_RADIUS_KM", 50),
+		SearchMinResults:      getEnvInt("SEARCH_MIN_RESULTS", 3),
+		SearchResultLimit:     getEnvInt("SEARCH_RESULT_LIMIT", 50),
+
+		LocationMaxAge:        getEnvDuration("LOCATION_MAX_AGE", 24*time.Hour),
+		LocationSweepInterval: getEnvDuration("LOCATION_SWEEP_INTERVAL", 10*time.Minute),
+		SearchLocationMaxAge:  getEnvDuration("SEARCH_LOCATION_MAX_AGE", 6*time.Hour),
+		LiveLocationInterval:  getEnvDuration("LIVE_LOCATION_CHECK_INTERVAL", time.Minute),
+
+		LocationPrivacyMode:   getEnv("LOCATION_PRIVACY_MODE", "grid"),
+		LocationGridMeters:    getEnvFloat("LOCATION_GRID_METERS", 500),
+		LocationJitterMeters:  getEnvFloat("LOCATION_JITTER_METERS", 500),
+		LocationPrivacySecret: os.Getenv("LOCATION_PRIVACY_SECRET"),
+		DistanceFloorKm:       getEnvFloat("DISTANCE_FLOOR_KM", 1),
+		LocationRawRetention:  getEnvDuration("LOCATION_RAW_RETENTION", 0),
+
+		SavedSearchLimit:       getEnvInt("SAVED_SEARCH_LIMIT", 5),
+		SavedSearchCooldown:    getEnvDuration("SAVED_SEARCH_COOLDOWN", 30*time.Minute),
+		SavedSearchRepeatAfter: getEnvDuration("SAVED_SEARCH_REPEAT_AFTER", 24*time.Hour),
+
+		ConnectDailyLimit: getEnvInt("CONNECT_DAILY_LIMIT", 20),
+		ConnectCooldown:   getEnvDuration("CONNECT_COOLDOWN", 24*time.Hour),
+		ConnectMaxPending: getEnvInt("CONNECT_MAX_PENDING", 5),
+		ConnectPendingTTL: getEnvDuration("CONNECT_PENDING_TTL", 24*time.Hour),
+	}
+
+	// Неизвестный GEO_BACKEND молча превращается в redis, поэтому в кластере проверяем явно
+	if len(cfg.RedisCluster) > 0 && !clusterGeoBackends[cfg.GeoBackend] {
+		log.Fatalf("GEO_BACKEND %q is not supported with REDIS_CLUSTER_ADDRS", cfg.GeoBackend)
+	}
+
+	// time.NewTicker паникует на неположительном интервале
+	if cfg.LocationSweepInterval <= 0 {
+		log.Fatalf("LOCATION_SWEEP_INTERVAL must be positive, got %s", cfg.LocationSweepInterval)
+	}
+	if cfg.LiveLocationInterval <= 0 {
+		log.Fatalf("LIVE_LOCATION_CHECK_INTERVAL must be positive, got %s", cfg.LiveLocationInterval)
+	}
+
+	return cfg
+}
+
+// clusterGeoBackends - гео-индексы, ключи которых совместимы с Redis Cluster
+var clusterGeoBackends = map[string]bool{
+	"redis":         true,
+	"redis_sharded": true,
+	"postgis":       true,
+	"memory":        true,
+}
+
+// getEnv читает строку из переменной окружения или возвращает значение по умолчанию
+func getEnv(key, def string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// getEnvList читает список значений через запятую из переменной окружения
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvInt читает целое число из переменной окружения или возвращает значение по умолчанию
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %d", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// getEnvBool читает логическое значение из переменной окружения или возвращает значение по умолчанию
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %t", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// getEnvFloat читает дробное число из переменной окружения или возвращает значение по умолчанию
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %g", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// getEnvDuration читает длительность (например, "6h" или "30m") из переменной окружения
+// или возвращает значение по умолчанию
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %s", key, value, def)
+		return def
 	}
+	return parsed
 }
@@ -47,6 +47,11 @@ var mainMenuCommands = []tgbotapi.BotCommand{
 	{Command: "current_visibility", Description: "Текущая видимость"},
 	{Command: "toggle_visibility", Description: "Включить/выключить видимость"},
 	{Command: "search", Description: "Начать поиск пользователей"},
+	{Command: "search_radius", Description: "Радиус поиска"},
+	{Command: "likes", Description: "Кому я понравился"},
+	{Command: "quota", Description: "Оставшиеся запросы на общение"},
+	{Command: "save_search", Description: "Сохранить поиск"},
+	{Command: "saved_searches", Description: "Сохраненные поиски"},
 	{Command: "help", Description: "Получить справку"},
 }
 
@@ -56,6 +61,11 @@ var mainMenuTitle = "📋 <b>Главное меню</b>\n\n" +
 	"👁 <b>/current_visibility</b> — Текущая видимость\n" +
 	"🔄 <b>/toggle_visibility</b> — Включить/выключить видимость\n" +
 	"🔍 <b>/search</b> — Начать поиск пользователей\n" +
+	"📏 <b>/search_radius</b> — Радиус поиска\n" +
+	"❤️ <b>/likes</b> — Кому вы понравились\n" +
+	"📨 <b>/quota</b> — Оставшиеся запросы на общение\n" +
+	"🔔 <b>/save_search</b> — Сохранить поиск и получать уведомления\n" +
+	"🗂 <b>/saved_searches</b> — Сохраненные поиски\n" +
 	"ℹ️ <b>/help</b> — Получить справку\n"
 
 var profileCommands = []tgbotapi.BotCommand{
@@ -21,6 +21,12 @@ const (
 	StepSetLocationForVisibility = "step_set_location_for_visibility"
 )
 
+const (
+	StepSavedSearchGender   = "step_saved_search_gender"
+	StepSavedSearchAge      = "step_saved_search_age"
+	StepSavedSearchLocation = "step_saved_search_location"
+)
+
 type FSM struct {
 	cache *cache.MemcacheClient
 }
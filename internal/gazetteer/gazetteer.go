@@ -0,0 +1,233 @@
+package gazetteer
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed data/places.tsv
+var defaultPlaces string
+
+// Place - населенный пункт или район из справочника
+type Place struct {
+	ID          int
+	Name        string
+	Latitude    float64
+	Longitude   float64
+	FeatureCode string
+	CountryCode string
+	Population  int
+	Parent      *Place // Город, в котором находится район (nil для городов)
+
+	names    []string // Нормализованные название и альтернативные названия
+	parentID int
+}
+
+// FullName возвращает название вместе с родительским городом, например "Арбат, Москва"
+func (p *Place) FullName() string {
+	if p.Parent == nil {
+		return p.Name
+	}
+	return p.Name + ", " + p.Parent.Name
+}
+
+// Gazetteer - офлайн-справочник мест, который сопоставляет введенные названия с координатами
+type Gazetteer struct {
+	places []*Place
+	byName map[string][]*Place
+}
+
+// LoadDefault загружает справочник, встроенный в бинарник
+func LoadDefault() (*Gazetteer, error) {
+	return Load(strings.NewReader(defaultPlaces))
+}
+
+// LoadFile загружает справочник из файла в том же формате, что и встроенный
+func LoadFile(path string) (*Gazetteer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Load(file)
+}
+
+// Load читает справочник в формате TSV:
+// id, name, alternate_names (через запятую), latitude, longitude, feature_code, country_code, parent_id, population.
+// Строки, начинающиеся с #, пропускаются.
+func Load(r io.Reader) (*Gazetteer, error) {
+	g := &Gazetteer{byName: make(map[string][]*Place)}
+	byID := make(map[int]*Place)
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		place, alternateNames, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		place.names = append(place.names, normalize(place.Name))
+		for _, name := range alternateNames {
+			if name = normalize(name); name != "" {
+				place.names = append(place.names, name)
+			}
+		}
+		for _, name := range place.names {
+			g.byName[name] = append(g.byName[name], place)
+		}
+
+		g.places = append(g.places, place)
+		byID[place.ID] = place
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Связываем районы с городами
+	for _, place := range g.places {
+		if place.parentID != 0 {
+			place.Parent = byID[place.parentID]
+		}
+	}
+
+	return g, nil
+}
+
+func parseLine(line string) (*Place, []string, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 9 {
+		return nil, nil, fmt.Errorf("expected 9 columns, got %d", len(fields))
+	}
+
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid id: %v", err)
+	}
+	latitude, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid latitude: %v", err)
+	}
+	longitude, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid longitude: %v", err)
+	}
+	parentID, err := strconv.Atoi(fields[7])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid parent_id: %v", err)
+	}
+	population, err := strconv.Atoi(fields[8])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid population: %v", err)
+	}
+
+	place := &Place{
+		ID:          id,
+		Name:        fields[1],
+		Latitude:    latitude,
+		Longitude:   longitude,
+		FeatureCode: fields[5],
+		CountryCode: fields[6],
+		Population:  population,
+		parentID:    parentID,
+	}
+
+	var alternateNames []string
+	if fields[2] != "" {
+		alternateNames = strings.Split(fields[2], ",")
+	}
+	return place, alternateNames, nil
+}
+
+// Resolve находит место по введенному тексту: "Москва", "Moscow, Arbat", "Арбат, Москва" или "Арбат".
+// Части через запятую должны совпасть с самим местом и его родительским городом.
+// При нескольких подходящих местах выбирается самое конкретное (район), затем самое крупное.
+func (g *Gazetteer) Resolve(query string) (*Place, bool) {
+	var parts []string
+	for _, part := range strings.Split(query, ",") {
+		if part = normalize(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, false
+	}
+
+	var candidates []*Place
+	for _, part := range parts {
+		for _, place := range g.byName[part] {
+			if matchesAll(place, parts) {
+				candidates = append(candidates, place)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if depth(candidates[i]) != depth(candidates[j]) {
+			return depth(candidates[i]) > depth(candidates[j])
+		}
+		return candidates[i].Population > candidates[j].Population
+	})
+
+	return candidates[0], true
+}
+
+// matchesAll проверяет, что каждая часть запроса - название самого места или одного из его родителей
+func matchesAll(place *Place, parts []string) bool {
+	for _, part := range parts {
+		matched := false
+		for p := place; p != nil && !matched; p = p.Parent {
+			matched = p.hasName(part)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Place) hasName(name string) bool {
+	for _, n := range p.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func depth(place *Place) int {
+	d := 0
+	for p := place.Parent; p != nil; p = p.Parent {
+		d++
+	}
+	return d
+}
+
+// normalize приводит название к виду для сравнения: нижний регистр, "ё" -> "е",
+// дефисы и лишние пробелы заменяются одним пробелом, слова "город" и "район" отбрасываются
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "ё", "е")
+	name = strings.ReplaceAll(name, "-", " ")
+	name = strings.TrimPrefix(name, "г. ")
+	name = strings.TrimPrefix(name, "город ")
+	name = strings.TrimPrefix(name, "район ")
+	name = strings.TrimSuffix(name, " район")
+	return strings.Join(strings.Fields(name), " ")
+}
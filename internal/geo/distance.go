@@ -0,0 +1,45 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Neighbor - пользователь, найденный рядом, и расстояние до него в километрах
+type Neighbor struct {
+	UserID     int64
+	DistanceKm float64
+}
+
+// FormatDistance округляет расстояние до приблизительных интервалов, чтобы по нему
+// нельзя было точно определить местоположение пользователя.
+// Расстояния меньше floorKm (но не меньше 1 км) показываются как "<N км".
+func FormatDistance(distanceKm, floorKm float64) string {
+	floor := math.Max(math.Ceil(floorKm), 1)
+	switch {
+	case distanceKm < floor:
+		return fmt.Sprintf("<%d км", int(floor))
+	case distanceKm < 10:
+		return fmt.Sprintf("~%d км", int(math.Round(distanceKm)))
+	case distanceKm < 50:
+		return fmt.Sprintf("~%d км", roundTo(distanceKm, 5))
+	default:
+		return fmt.Sprintf("~%d км", roundTo(distanceKm, 10))
+	}
+}
+
+// roundTo округляет значение до ближайшего кратного step
+func roundTo(value float64, step int) int {
+	return int(math.Round(value/float64(step))) * step
+}
+
+const earthRadiusKm = 6371.0
+
+// HaversineKm возвращает расстояние по поверхности Земли между двумя точками в километрах
+func HaversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,106 @@
+package geo
+
+import "math"
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash кодирует координаты в geohash заданной длины
+func EncodeGeohash(latitude, longitude float64, precision int) string {
+	latMin, latMax := -90.0, 90.0
+	lonMin, lonMax := -180.0, 180.0
+
+	hash := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true // Биты чередуются: четные - долгота, нечетные - широта
+
+	for len(hash) < precision {
+		if even {
+			mid := (lonMin + lonMax) / 2
+			if longitude >= mid {
+				ch |= 1 << (4 - bit)
+				lonMin = mid
+			} else {
+				lonMax = mid
+			}
+		} else {
+			mid := (latMin + latMax) / 2
+			if latitude >= mid {
+				ch |= 1 << (4 - bit)
+				latMin = mid
+			} else {
+				latMax = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+// GeohashCellSize возвращает размер ячейки geohash заданной длины в градусах (широта, долгота)
+func GeohashCellSize(precision int) (float64, float64) {
+	bits := precision * 5
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+// Bounds возвращает прямоугольник (minLat, minLon, maxLat, maxLon), покрывающий область запроса с центром в точке
+func (q Query) Bounds(latitude, longitude float64) (float64, float64, float64, float64) {
+	halfHeightKm, halfWidthKm := q.RadiusKm, q.RadiusKm
+	if q.IsBox() {
+		halfHeightKm, halfWidthKm = q.HeightKm/2, q.WidthKm/2
+	}
+
+	dLat := halfHeightKm / earthRadiusKm * 180 / math.Pi
+	dLon := halfWidthKm / (earthRadiusKm * math.Max(math.Cos(latitude*math.Pi/180), 0.01)) * 180 / math.Pi
+
+	return math.Max(latitude-dLat, -90), longitude - dLon, math.Min(latitude+dLat, 90), longitude + dLon
+}
+
+// Contains проверяет, попадает ли точка в область запроса с центром в (latitude, longitude),
+// и возвращает расстояние до нее
+func (q Query) Contains(latitude, longitude, pointLat, pointLon float64) (float64, bool) {
+	distance := HaversineKm(latitude, longitude, pointLat, pointLon)
+	if !q.IsBox() {
+		return distance, distance <= q.RadiusKm
+	}
+
+	minLat, minLon, maxLat, maxLon := q.Bounds(latitude, longitude)
+	if pointLon < minLon {
+		pointLon += 360
+	} else if pointLon > maxLon {
+		pointLon -= 360
+	}
+	return distance, pointLat >= minLat && pointLat <= maxLat && pointLon >= minLon && pointLon <= maxLon
+}
+
+// CoverGeohashes возвращает все ячейки geohash заданной длины, пересекающие прямоугольник
+func CoverGeohashes(minLat, minLon, maxLat, maxLon float64, precision int) []string {
+	latStep, lonStep := GeohashCellSize(precision)
+
+	// Шагаем по центрам ячеек, начиная с ячейки, содержащей угол прямоугольника
+	startLat := math.Floor((minLat+90)/latStep)*latStep - 90 + latStep/2
+	startLon := math.Floor((minLon+180)/lonStep)*lonStep - 180 + lonStep/2
+
+	seen := make(map[string]bool)
+	var hashes []string
+	for lat := startLat; lat < maxLat+latStep/2; lat += latStep {
+		for lon := startLon; lon < maxLon+lonStep/2; lon += lonStep {
+			hash := EncodeGeohash(math.Max(-90, math.Min(90, lat)), clampLongitude(lon), precision)
+			if !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+
+	return hashes
+}
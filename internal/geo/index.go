@@ -0,0 +1,17 @@
+package geo
+
+import "time"
+
+// Index - гео-индекс видимых пользователей
+type Index interface {
+	// Add добавляет или обновляет локацию пользователя
+	Add(userID int64, latitude, longitude float64) error
+	// Remove удаляет пользователя из индекса
+	Remove(userID int64) error
+	// Nearby ищет пользователей по запросу, от ближайших к дальним
+	Nearby(q Query) ([]Neighbor, error)
+	// Position возвращает сохраненную локацию пользователя
+	Position(userID int64) (latitude, longitude float64, err error)
+	// RemoveStale удаляет пользователей, чья локация не обновлялась дольше maxAge, и возвращает их ID
+	RemoveStale(maxAge time.Duration) ([]int64, error)
+}
@@ -0,0 +1,140 @@
+package geo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryPrecision - длина geohash ячеек в MemoryIndex (~5 x 5 км на экваторе)
+const memoryPrecision = 5
+
+type memoryEntry struct {
+	latitude  float64
+	longitude float64
+	cell      string
+	updatedAt time.Time
+}
+
+// MemoryIndex - гео-индекс в памяти процесса на основе ячеек geohash.
+// Не требует внешних сервисов, подходит для локального запуска и тестов.
+type MemoryIndex struct {
+	mu           sync.RWMutex
+	positions    map[int64]memoryEntry
+	cells        map[string]map[int64]struct{}
+	searchMaxAge time.Duration // Пользователи с более старой локацией не попадают в поиск
+}
+
+// NewMemoryIndex создает пустой гео-индекс в памяти
+func NewMemoryIndex(searchMaxAge time.Duration) *MemoryIndex {
+	return &MemoryIndex{
+		positions:    make(map[int64]memoryEntry),
+		cells:        make(map[string]map[int64]struct{}),
+		searchMaxAge: searchMaxAge,
+	}
+}
+
+func (m *MemoryIndex) Add(userID int64, latitude, longitude float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeLocked(userID)
+
+	cell := EncodeGeohash(latitude, longitude, memoryPrecision)
+	m.positions[userID] = memoryEntry{latitude: latitude, longitude: longitude, cell: cell, updatedAt: time.Now()}
+	if m.cells[cell] == nil {
+		m.cells[cell] = make(map[int64]struct{})
+	}
+	m.cells[cell][userID] = struct{}{}
+	return nil
+}
+
+func (m *MemoryIndex) Remove(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeLocked(userID)
+	return nil
+}
+
+func (m *MemoryIndex) Nearby(q Query) ([]Neighbor, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latitude, longitude := q.Latitude, q.Longitude
+	if q.FromUserID != 0 {
+		entry, ok := m.positions[q.FromUserID]
+		if !ok {
+			return nil, fmt.Errorf("location of user %d not found", q.FromUserID)
+		}
+		latitude, longitude = entry.latitude, entry.longitude
+	}
+
+	staleBefore := time.Now().Add(-m.searchMaxAge)
+	minLat, minLon, maxLat, maxLon := q.Bounds(latitude, longitude)
+
+	var neighbors []Neighbor
+	for _, cell := range CoverGeohashes(minLat, minLon, maxLat, maxLon, memoryPrecision) {
+		for userID := range m.cells[cell] {
+			if q.Excludes(userID) {
+				continue
+			}
+			entry := m.positions[userID]
+			if m.searchMaxAge > 0 && entry.updatedAt.Before(staleBefore) {
+				continue
+			}
+			if distance, ok := q.Contains(latitude, longitude, entry.latitude, entry.longitude); ok {
+				neighbors = append(neighbors, Neighbor{UserID: userID, DistanceKm: distance})
+			}
+		}
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].DistanceKm < neighbors[j].DistanceKm
+	})
+	if q.Limit > 0 && len(neighbors) > q.Limit {
+		neighbors = neighbors[:q.Limit]
+	}
+
+	return neighbors, nil
+}
+
+func (m *MemoryIndex) Position(userID int64) (float64, float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.positions[userID]
+	if !ok {
+		return 0, 0, fmt.Errorf("location of user %d not found", userID)
+	}
+	return entry.latitude, entry.longitude, nil
+}
+
+func (m *MemoryIndex) RemoveStale(maxAge time.Duration) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	var removed []int64
+	for userID, entry := range m.positions {
+		if entry.updatedAt.Before(cutoff) {
+			m.removeLocked(userID)
+			removed = append(removed, userID)
+		}
+	}
+	return removed, nil
+}
+
+// removeLocked удаляет пользователя из индекса. Вызывается под m.mu.
+func (m *MemoryIndex) removeLocked(userID int64) {
+	entry, ok := m.positions[userID]
+	if !ok {
+		return
+	}
+	delete(m.positions, userID)
+	delete(m.cells[entry.cell], userID)
+	if len(m.cells[entry.cell]) == 0 {
+		delete(m.cells, entry.cell)
+	}
+}
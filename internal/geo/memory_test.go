@@ -0,0 +1,251 @@
+package geo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Центр поиска и точки вокруг него (Москва, центр)
+const (
+	centerLat = 55.7558
+	centerLon = 37.6173
+)
+
+func TestEncodeGeohash(t *testing.T) {
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		precision int
+		want      string
+	}{
+		{"известная точка", 57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{"короткий хеш", 57.64911, 10.40744, 5, "u4pru"},
+		{"начало координат", 0, 0, 5, "s0000"},
+		{"юго-западный угол", -90, -180, 3, "000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeGeohash(tt.latitude, tt.longitude, tt.precision); got != tt.want {
+				t.Errorf("EncodeGeohash(%v, %v, %d) = %q, want %q", tt.latitude, tt.longitude, tt.precision, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverGeohashesContainsCorners(t *testing.T) {
+	minLat, minLon, maxLat, maxLon := centerLat-0.2, centerLon-0.3, centerLat+0.2, centerLon+0.3
+	cells := make(map[string]bool)
+	for _, cell := range CoverGeohashes(minLat, minLon, maxLat, maxLon, memoryPrecision) {
+		cells[cell] = true
+	}
+
+	for _, corner := range [][2]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, minLon}, {maxLat, maxLon}, {centerLat, centerLon}} {
+		if cell := EncodeGeohash(corner[0], corner[1], memoryPrecision); !cells[cell] {
+			t.Errorf("cell %s of point %v is not covered", cell, corner)
+		}
+	}
+}
+
+func TestMemoryIndexNearby(t *testing.T) {
+	// Пользователи к северу и к западу от центра на известных расстояниях; 5 и 6 - в соседних ячейках geohash
+	points := map[int64][2]float64{
+		1: {centerLat, centerLon},
+		2: {centerLat + 0.01, centerLon},
+		3: {centerLat + 0.03, centerLon},
+		4: {centerLat + 0.05, centerLon},
+		5: {centerLat + 0.2, centerLon},
+		6: {centerLat, centerLon - 0.3},
+	}
+	distance := func(userID int64) float64 {
+		return HaversineKm(centerLat, centerLon, points[userID][0], points[userID][1])
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []int64
+	}{
+		{
+			name:  "радиус включает точку на границе",
+			query: Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: distance(3)},
+			want:  []int64{1, 2, 3},
+		},
+		{
+			name:  "точка чуть дальше радиуса не попадает",
+			query: Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: distance(3) - 0.001},
+			want:  []int64{1, 2},
+		},
+		{
+			name:  "большой радиус захватывает соседние ячейки",
+			query: Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: 30},
+			want:  []int64{1, 2, 3, 4, 6, 5},
+		},
+		{
+			name:  "поиск от пользователя исключает его самого",
+			query: Query{FromUserID: 1, ExcludeUserID: 1, RadiusKm: distance(4)},
+			want:  []int64{2, 3, 4},
+		},
+		{
+			name:  "исключенные пользователи",
+			query: Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: distance(4), ExcludeUserID: 1, ExcludeUserIDs: []int64{3}},
+			want:  []int64{2, 4},
+		},
+		{
+			name:  "лимит оставляет ближайших",
+			query: Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: 30, Limit: 2},
+			want:  []int64{1, 2},
+		},
+		{
+			name:  "лимит считается после исключений",
+			query: Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: 30, Limit: 2, ExcludeUserIDs: []int64{1, 2}},
+			want:  []int64{3, 4},
+		},
+		{
+			name:  "прямоугольник",
+			query: Query{Latitude: centerLat, Longitude: centerLon, WidthKm: 2, HeightKm: 8},
+			want:  []int64{1, 2, 3},
+		},
+		{
+			name:  "пусто",
+			query: Query{Latitude: -centerLat, Longitude: -centerLon, RadiusKm: 10},
+			want:  nil,
+		},
+	}
+
+	index := NewMemoryIndex(0)
+	for userID, point := range points {
+		if err := index.Add(userID, point[0], point[1]); err != nil {
+			t.Fatalf("Add(%d): %v", userID, err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			neighbors, err := index.Nearby(tt.query)
+			if err != nil {
+				t.Fatalf("Nearby: %v", err)
+			}
+			if got := neighborIDs(neighbors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Nearby() = %v, want %v", got, tt.want)
+			}
+			for i := 1; i < len(neighbors); i++ {
+				if neighbors[i].DistanceKm < neighbors[i-1].DistanceKm {
+					t.Errorf("results are not sorted by distance: %v", neighbors)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryIndexStaleness(t *testing.T) {
+	tests := []struct {
+		name         string
+		searchMaxAge time.Duration
+		age          map[int64]time.Duration
+		want         []int64
+	}{
+		{
+			name:         "устаревшие локации не попадают в поиск",
+			searchMaxAge: time.Hour,
+			age:          map[int64]time.Duration{1: 0, 2: 2 * time.Hour, 3: 30 * time.Minute},
+			want:         []int64{1, 3},
+		},
+		{
+			name:         "устаревшие не занимают места в лимите",
+			searchMaxAge: time.Hour,
+			age:          map[int64]time.Duration{1: 2 * time.Hour, 2: 2 * time.Hour, 3: 0},
+			want:         []int64{3},
+		},
+		{
+			name:         "без ограничения возраста старые локации не отсеиваются",
+			searchMaxAge: 0,
+			age:          map[int64]time.Duration{1: 0, 2: 48 * time.Hour, 3: 0},
+			want:         []int64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewMemoryIndex(tt.searchMaxAge)
+			for userID, age := range tt.age {
+				if err := index.Add(userID, centerLat+float64(userID)*0.001, centerLon); err != nil {
+					t.Fatalf("Add(%d): %v", userID, err)
+				}
+				entry := index.positions[userID]
+				entry.updatedAt = time.Now().Add(-age)
+				index.positions[userID] = entry
+			}
+
+			neighbors, err := index.Nearby(Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: 5, Limit: 2})
+			if err != nil {
+				t.Fatalf("Nearby: %v", err)
+			}
+			if got := neighborIDs(neighbors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Nearby() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexRemoveStale(t *testing.T) {
+	index := NewMemoryIndex(0)
+	for userID := int64(1); userID <= 3; userID++ {
+		if err := index.Add(userID, centerLat, centerLon); err != nil {
+			t.Fatalf("Add(%d): %v", userID, err)
+		}
+	}
+	entry := index.positions[2]
+	entry.updatedAt = time.Now().Add(-2 * time.Hour)
+	index.positions[2] = entry
+
+	removed, err := index.RemoveStale(time.Hour)
+	if err != nil {
+		t.Fatalf("RemoveStale: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []int64{2}) {
+		t.Errorf("RemoveStale() = %v, want [2]", removed)
+	}
+	if _, _, err := index.Position(2); err == nil {
+		t.Error("removed user still has a position")
+	}
+
+	neighbors, err := index.Nearby(Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: 1})
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if got := neighborIDs(neighbors); len(got) != 2 {
+		t.Errorf("Nearby() after RemoveStale = %v, want 2 users", got)
+	}
+}
+
+func TestMemoryIndexAddMovesUser(t *testing.T) {
+	index := NewMemoryIndex(0)
+	if err := index.Add(1, centerLat, centerLon); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := index.Add(1, -centerLat, -centerLon); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	neighbors, err := index.Nearby(Query{Latitude: centerLat, Longitude: centerLon, RadiusKm: 10})
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if len(neighbors) != 0 {
+		t.Errorf("user is still found at the old location: %v", neighbors)
+	}
+	if len(index.cells) != 1 {
+		t.Errorf("old cell was not cleaned up: %d cells", len(index.cells))
+	}
+}
+
+func neighborIDs(neighbors []Neighbor) []int64 {
+	var ids []int64
+	for _, neighbor := range neighbors {
+		ids = append(ids, neighbor.UserID)
+	}
+	return ids
+}
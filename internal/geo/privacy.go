@@ -0,0 +1,130 @@
+package geo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"geo_match_bot/internal/config"
+	"math"
+	"strconv"
+)
+
+// Режимы обфускации координат
+const (
+	PrivacyOff    = "off"    // Координаты сохраняются как есть
+	PrivacyGrid   = "grid"   // Координаты привязываются к центру ячейки сетки
+	PrivacyJitter = "jitter" // К координатам добавляется постоянное для пользователя смещение
+)
+
+const metersPerDegree = 111320.0
+
+// placeholderSecret - значение секрета из примера .env, с ним смещения можно вычислить
+const placeholderSecret = "change-me"
+
+// Privacy огрубляет координаты перед индексацией и округляет показываемые расстояния,
+// чтобы по ним нельзя было триангулировать пользователя
+type Privacy struct {
+	Mode            string
+	GridMeters      float64 // Размер ячейки сетки для режима grid
+	JitterMeters    float64 // Максимальное смещение для режима jitter
+	Secret          string  // Ключ, от которого зависит смещение пользователя
+	DistanceFloorKm float64 // Расстояния меньше этого значения показываются как "<N км"
+}
+
+// NewPrivacy создает настройки приватности из конфигурации
+func NewPrivacy(cfg *config.Config) Privacy {
+	return Privacy{
+		Mode:            cfg.LocationPrivacyMode,
+		GridMeters:      cfg.LocationGridMeters,
+		JitterMeters:    cfg.LocationJitterMeters,
+		Secret:          cfg.LocationPrivacySecret,
+		DistanceFloorKm: cfg.DistanceFloorKm,
+	}
+}
+
+// Validate проверяет, что для режима jitter задан настоящий секрет:
+// без него смещение пользователя можно вычислить и вычесть из координат
+func (p Privacy) Validate() error {
+	if p.Mode != PrivacyJitter {
+		return nil
+	}
+	if p.Secret == "" || p.Secret == placeholderSecret {
+		return errors.New("LOCATION_PRIVACY_SECRET must be set to a random value in jitter mode")
+	}
+	return nil
+}
+
+// Obfuscate возвращает координаты, которые можно сохранить в гео-индексе
+func (p Privacy) Obfuscate(userID int64, latitude, longitude float64) (float64, float64) {
+	switch p.Mode {
+	case PrivacyGrid:
+		return snapToGrid(latitude, longitude, p.GridMeters)
+	case PrivacyJitter:
+		return p.jitter(userID, latitude, longitude)
+	default:
+		return latitude, longitude
+	}
+}
+
+// FormatDistance форматирует расстояние с учетом минимального показываемого значения
+func (p Privacy) FormatDistance(distanceKm float64) string {
+	return FormatDistance(distanceKm, p.DistanceFloorKm)
+}
+
+// snapToGrid привязывает координаты к центру ячейки размером cellMeters
+func snapToGrid(latitude, longitude, cellMeters float64) (float64, float64) {
+	if cellMeters <= 0 {
+		return latitude, longitude
+	}
+
+	latStep := cellMeters / metersPerDegree
+	snappedLat := math.Floor(latitude/latStep)*latStep + latStep/2
+
+	// Шаг по долготе считаем от широты центра ячейки, чтобы он был одинаковым для всей ячейки
+	lonStep := cellMeters / (metersPerDegree * math.Max(math.Cos(snappedLat*math.Pi/180), 0.01))
+	snappedLon := math.Floor(longitude/lonStep)*lonStep + lonStep/2
+
+	return clampLatitude(snappedLat), clampLongitude(snappedLon)
+}
+
+// jitter смещает координаты на расстояние до JitterMeters в направлении, которое
+// зависит только от пользователя и секрета. Повторные отправки локации дают
+// одинаковое смещение, поэтому его нельзя усреднить.
+func (p Privacy) jitter(userID int64, latitude, longitude float64) (float64, float64) {
+	if p.JitterMeters <= 0 {
+		return latitude, longitude
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	mac.Write([]byte(strconv.FormatInt(userID, 10)))
+	sum := mac.Sum(nil)
+
+	u1 := float64(binary.BigEndian.Uint64(sum[0:8])) / float64(math.MaxUint64)
+	u2 := float64(binary.BigEndian.Uint64(sum[8:16])) / float64(math.MaxUint64)
+
+	// Равномерное распределение точки внутри круга радиусом JitterMeters
+	distance := p.JitterMeters * math.Sqrt(u1)
+	angle := 2 * math.Pi * u2
+
+	dLat := distance * math.Cos(angle) / metersPerDegree
+	dLon := distance * math.Sin(angle) / (metersPerDegree * math.Max(math.Cos(latitude*math.Pi/180), 0.01))
+
+	return clampLatitude(latitude + dLat), clampLongitude(longitude + dLon)
+}
+
+// clampLatitude ограничивает широту диапазоном, который поддерживает гео-индекс Redis
+func clampLatitude(latitude float64) float64 {
+	return math.Max(-85, math.Min(85, latitude))
+}
+
+// clampLongitude приводит долготу к диапазону [-180, 180)
+func clampLongitude(longitude float64) float64 {
+	for longitude >= 180 {
+		longitude -= 360
+	}
+	for longitude < -180 {
+		longitude += 360
+	}
+	return longitude
+}
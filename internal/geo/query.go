@@ -0,0 +1,46 @@
+package geo
+
+// Query описывает поиск соседей в гео-индексе.
+// Центр поиска - локация пользователя FromUserID или координаты Latitude/Longitude.
+// Форма - круг радиусом RadiusKm или прямоугольник WidthKm x HeightKm.
+// Результаты всегда отсортированы от ближайших к дальним.
+type Query struct {
+	FromUserID int64 // Если задан, поиск ведется от сохраненной локации этого пользователя
+	Latitude   float64
+	Longitude  float64
+
+	RadiusKm float64 // Поиск по кругу
+	WidthKm  float64 // Поиск по прямоугольнику, если RadiusKm не задан
+	HeightKm float64
+
+	Limit          int     // Максимальное число результатов (0 - без ограничения)
+	ExcludeUserID  int64   // Пользователь, которого не нужно включать в результаты (обычно сам ищущий)
+	ExcludeUserIDs []int64 // Другие исключаемые пользователи (например, заблокированные)
+}
+
+// Excluded возвращает всех пользователей, которых не нужно включать в результаты
+func (q Query) Excluded() []int64 {
+	excluded := q.ExcludeUserIDs
+	if q.ExcludeUserID != 0 {
+		excluded = append([]int64{q.ExcludeUserID}, excluded...)
+	}
+	return excluded
+}
+
+// Excludes сообщает, исключен ли пользователь из результатов
+func (q Query) Excludes(userID int64) bool {
+	if userID == q.ExcludeUserID {
+		return true
+	}
+	for _, excluded := range q.ExcludeUserIDs {
+		if userID == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBox сообщает, задан ли поиск по прямоугольнику
+func (q Query) IsBox() bool {
+	return q.RadiusKm <= 0 && q.WidthKm > 0 && q.HeightKm > 0
+}
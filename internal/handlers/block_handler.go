@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type BlockHandler interface {
+	BlockUser(telegramID, targetID int64)
+	UnblockUser(telegramID, targetID int64)
+}
+
+// BlockUser блокирует пользователя: пара больше не видит друг друга в поиске,
+// не может отправлять запросы на общение, а текущий чат с ним завершается
+func (h *UpdateHandler) BlockUser(telegramID, targetID int64) {
+	if telegramID == targetID {
+		return
+	}
+
+	err := h.blockRepository.Block(telegramID, targetID)
+	if err != nil {
+		log.Printf("Error blocking user: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при блокировке пользователя. Попробуйте позже."))
+		return
+	}
+
+	// Запросы на общение между ними больше не ждут ответа
+	h.resolveConnect(telegramID, targetID)
+	h.resolveConnect(targetID, telegramID)
+
+	// Убираем друг друга из результатов поиска, которые пользователи еще листают
+	h.carousel.Forget(telegramID, targetID)
+	h.carousel.Forget(targetID, telegramID)
+
+	// Если пользователи сейчас общаются, завершаем чат
+	chatPartner, err := h.cache.Get(fmt.Sprintf("chat:%d", telegramID))
+	if err == nil && chatPartner == fmt.Sprintf("%d", targetID) {
+		h.EndChat(telegramID, targetID)
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, "Пользователь заблокирован. Вы больше не увидите друг друга в поиске и не получите друг от друга запросов на общение.")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Разблокировать", fmt.Sprintf("unblock_%d", targetID)),
+		),
+	)
+	h.bot.Send(msg)
+}
+
+// UnblockUser снимает блокировку, поставленную пользователем
+func (h *UpdateHandler) UnblockUser(telegramID, targetID int64) {
+	err := h.blockRepository.Unblock(telegramID, targetID)
+	if err != nil {
+		log.Printf("Error unblocking user: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при разблокировке пользователя. Попробуйте позже."))
+		return
+	}
+
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "Пользователь разблокирован."))
+}
+
+// isBlocked проверяет блокировку между пользователями в любую сторону.
+// При ошибке считаем пару заблокированной, чтобы не показать лишнего.
+func (h *UpdateHandler) isBlocked(telegramID1, telegramID2 int64) bool {
+	blocked, err := h.blockRepository.IsBlocked(telegramID1, telegramID2)
+	if err != nil {
+		log.Printf("Error checking block: %v", err)
+		return true
+	}
+	return blocked
+}
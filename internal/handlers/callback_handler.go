@@ -26,6 +26,8 @@ func (h *UpdateHandler) HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuer
 	if strings.HasPrefix(callbackQuery.Data, "accept_") {
 		// Извлекаем ID пользователя, отправившего запрос
 		senderID := strings.TrimPrefix(callbackQuery.Data, "accept_")
+		senderIDInt64, _ := strconv.ParseInt(senderID, 10, 64)
+		h.resolveConnect(senderIDInt64, telegramID)
 		h.StartChat(telegramID, senderID)
 		return
 	}
@@ -35,10 +37,86 @@ func (h *UpdateHandler) HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuer
 		senderID := strings.TrimPrefix(callbackQuery.Data, "decline_")
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Вы отказали в общении."))
 		senderIDInt64, _ := strconv.ParseInt(senderID, 10, 64)
+		h.resolveConnect(senderIDInt64, telegramID)
 		h.bot.Send(tgbotapi.NewMessage(senderIDInt64, "Ваш запрос на общение был отклонен."))
 		return
 	}
 
+	// Листание результатов поиска
+	if strings.HasPrefix(callbackQuery.Data, "carousel_") {
+		index, err := strconv.Atoi(strings.TrimPrefix(callbackQuery.Data, "carousel_"))
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректная команда."))
+			return
+		}
+		h.carousel.Navigate(callbackQuery, index)
+		return
+	}
+
+	// Отметки в режиме взаимных симпатий
+	if strings.HasPrefix(callbackQuery.Data, "like_") {
+		targetID, err := strconv.ParseInt(strings.TrimPrefix(callbackQuery.Data, "like_"), 10, 64)
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный пользователь."))
+			return
+		}
+		h.LikeUser(telegramID, targetID)
+		return
+	}
+
+	if strings.HasPrefix(callbackQuery.Data, "pass_") {
+		targetID, err := strconv.ParseInt(strings.TrimPrefix(callbackQuery.Data, "pass_"), 10, 64)
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный пользователь."))
+			return
+		}
+		h.PassUser(telegramID, targetID)
+		return
+	}
+
+	// Блокировка и разблокировка пользователя
+	if strings.HasPrefix(callbackQuery.Data, "block_") {
+		targetID, err := strconv.ParseInt(strings.TrimPrefix(callbackQuery.Data, "block_"), 10, 64)
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный пользователь."))
+			return
+		}
+		h.BlockUser(telegramID, targetID)
+		return
+	}
+
+	if strings.HasPrefix(callbackQuery.Data, "unblock_") {
+		targetID, err := strconv.ParseInt(strings.TrimPrefix(callbackQuery.Data, "unblock_"), 10, 64)
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный пользователь."))
+			return
+		}
+		h.UnblockUser(telegramID, targetID)
+		return
+	}
+
+	// Выбор радиуса поиска
+	if strings.HasPrefix(callbackQuery.Data, "radius_") {
+		radiusKm, err := strconv.ParseFloat(strings.TrimPrefix(callbackQuery.Data, "radius_"), 64)
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный радиус."))
+			return
+		}
+		h.SetSearchRadius(telegramID, radiusKm)
+		return
+	}
+
+	// Удаление сохраненного поиска
+	if strings.HasPrefix(callbackQuery.Data, "saved_search_delete_") {
+		searchID, err := strconv.Atoi(strings.TrimPrefix(callbackQuery.Data, "saved_search_delete_"))
+		if err != nil {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный поиск."))
+			return
+		}
+		h.DeleteSavedSearch(telegramID, searchID)
+		return
+	}
+
 	// Стандартные действия для других кнопок
 	switch callbackQuery.Data {
 	case "edit_profile":
@@ -49,6 +127,8 @@ func (h *UpdateHandler) HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuer
 		h.StartSearchProcess(telegramID)
 	case "search_next":
 		h.SearchNextUser(telegramID)
+	case "live_stop":
+		h.StopLiveLocation(telegramID)
 	default:
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Неизвестная команда."))
 	}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"strconv"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -13,14 +14,39 @@ type ChatHandler interface {
 }
 
 func (h *UpdateHandler) StartChat(userID1 int64, userID2 string) {
-	// Переводим двух пользователей в режим общения
-	h.cache.Set(fmt.Sprintf("chat:%d", userID1), userID2)
-	h.cache.Set(fmt.Sprintf("chat:%s", userID2), strconv.FormatInt(userID1, 10))
+	// Пользователь мог заблокировать собеседника после отправки запроса
+	userID2Int, _ := strconv.ParseInt(userID2, 10, 64)
+	if h.isBlocked(userID1, userID2Int) {
+		h.bot.Send(tgbotapi.NewMessage(userID1, "Пользователь недоступен для общения."))
+		return
+	}
+
+	// Переводим двух пользователей в режим общения, только если ни один из них уже не общается
+	started, err := h.cache.Add(fmt.Sprintf("chat:%d", userID1), userID2)
+	if err != nil {
+		log.Printf("Error starting chat: %v", err)
+		return
+	}
+	if !started {
+		h.bot.Send(tgbotapi.NewMessage(userID1, "У вас уже есть активный чат. Завершите его, чтобы начать новый."))
+		return
+	}
+	started, err = h.cache.Add(fmt.Sprintf("chat:%s", userID2), strconv.FormatInt(userID1, 10))
+	if err != nil || !started {
+		h.cache.Delete(fmt.Sprintf("chat:%d", userID1))
+		if err != nil {
+			log.Printf("Error starting chat: %v", err)
+			return
+		}
+		h.bot.Send(tgbotapi.NewMessage(userID1, "Пользователь сейчас общается с кем-то другим. Попробуйте позже."))
+		return
+	}
 
 	// Кнопка завершения чата для пользователя 1
 	keyboard1 := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
 			tgbotapi.NewKeyboardButton("Завершить общение"),
+			tgbotapi.NewKeyboardButton("Заблокировать собеседника"),
 		),
 	)
 
@@ -28,6 +54,7 @@ func (h *UpdateHandler) StartChat(userID1 int64, userID2 string) {
 	keyboard2 := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
 			tgbotapi.NewKeyboardButton("Завершить общение"),
+			tgbotapi.NewKeyboardButton("Заблокировать собеседника"),
 		),
 	)
 
@@ -36,7 +63,6 @@ func (h *UpdateHandler) StartChat(userID1 int64, userID2 string) {
 	msg1.ReplyMarkup = keyboard1
 	h.bot.Send(msg1)
 
-	userID2Int, _ := strconv.ParseInt(userID2, 10, 64)
 	msg2 := tgbotapi.NewMessage(userID2Int, "Вы начали общение. Отправьте сообщение, чтобы начать переписку.")
 	msg2.ReplyMarkup = keyboard2
 	h.bot.Send(msg2)
@@ -30,6 +30,16 @@ func (h *UpdateHandler) HandleCommand(update tgbotapi.Update) {
 		h.HandleCurrentVisibility(update)
 	case "toggle_visibility":
 		h.HandleToogleVisibility(update)
+	case "search_radius":
+		h.ShowRadiusSettings(update.Message.Chat.ID)
+	case "quota":
+		h.ShowConnectQuota(update.Message.Chat.ID)
+	case "likes":
+		h.ShowLikers(update.Message.Chat.ID)
+	case "save_search":
+		h.StartSaveSearch(update.Message.Chat.ID)
+	case "saved_searches":
+		h.ShowSavedSearches(update.Message.Chat.ID)
 	case "edit_profile":
 		h.bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Введите ваше имя:"))
 		h.fsm.SetState(update.Message.Chat.ID, fsm.StepTitleName) // Переход к шагу заполнения имени
@@ -127,8 +137,15 @@ func (h *UpdateHandler) HandleCurrentVisibility(update tgbotapi.Update) {
 	if err != nil {
 		if err == memcache.ErrCacheMiss {
 			h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("%d, %s", 125, err.Error())))
-			h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), "false")
-			currentVisibilityStr = "false"
+			// Кэш мог быть сброшен - видимость хранится в БД
+			visible, dbErr := h.userRepository.GetUserVisible(telegramID)
+			if dbErr != nil {
+				log.Printf("Error getting visibility: %v", dbErr)
+				h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении статуса видимости. Попробуйте позже."))
+				return
+			}
+			currentVisibilityStr = strconv.FormatBool(visible)
+			h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), currentVisibilityStr)
 		} else {
 			h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Ошибка при получении статуса видимости. Попробуйте позже. %s", err.Error())))
 			return
@@ -161,8 +178,15 @@ func (h *UpdateHandler) HandleToogleVisibility(update tgbotapi.Update) {
 	currentVisibilityStr, err := h.cache.Get(fmt.Sprintf("visibility:%d", telegramID))
 	if err != nil {
 		if err == memcache.ErrCacheMiss {
-			h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), "false")
-			currentVisibilityStr = "false"
+			// Кэш мог быть сброшен - видимость хранится в БД
+			visible, dbErr := h.userRepository.GetUserVisible(telegramID)
+			if dbErr != nil {
+				log.Printf("Error getting visibility: %v", dbErr)
+				h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении статуса видимости. Попробуйте позже."))
+				return
+			}
+			currentVisibilityStr = strconv.FormatBool(visible)
+			h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), currentVisibilityStr)
 		} else {
 			h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Ошибка при получении статуса видимости. Попробуйте позже. %s", err.Error())))
 			return
@@ -176,13 +200,15 @@ func (h *UpdateHandler) HandleToogleVisibility(update tgbotapi.Update) {
 	currentVisibility := !visible
 
 	if currentVisibility {
-		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, отправьте свою геолокацию для включения видимости."))
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Для включения видимости укажите местоположение. "+locationRequestText))
 		h.fsm.SetState(telegramID, fsm.StepSetLocationForVisibility)
 	} else {
+		if err := h.turnOffVisibility(telegramID); err != nil {
+			log.Printf("Error turning off visibility: %v", err)
+			h.bot.Send(tgbotapi.NewMessage(telegramID, turnOffVisibilityErrorText))
+			return
+		}
 		txt := `Вы <b>отключили</b> видимость, ваш профиль не отображается в поиске.`
-		go h.redisClient.RemoveUserLocation(telegramID)
-		go h.kafkaProducer.Produce("geo-match-search", "user_remove", fmt.Sprintf("%d", telegramID))
-		go h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), "false")
 		msg := tgbotapi.NewMessage(telegramID, txt)
 		msg.ParseMode = "HTML"
 		h.bot.Send(msg)
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type ConnectLimitHandler interface {
+	ShowConnectQuota(telegramID int64)
+}
+
+// ShowConnectQuota показывает, сколько запросов на общение пользователь еще может отправить
+func (h *UpdateHandler) ShowConnectQuota(telegramID int64) {
+	quota, err := h.connectLimiter.Quota(telegramID)
+	if err != nil {
+		log.Printf("Error getting connect quota: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении лимитов. Попробуйте позже."))
+		return
+	}
+
+	h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf(
+		"Запросов на общение сегодня осталось: %s\nМожно отправить еще без ответа: %s",
+		formatQuota(quota.DailyLeft, quota.DailyLimit), formatQuota(quota.PendingLeft, quota.MaxPending))))
+}
+
+// formatQuota выводит остаток лимита ("3 из 20"); нулевой лимит означает отсутствие ограничения
+func formatQuota(left, limit int) string {
+	if limit <= 0 {
+		return "без ограничений"
+	}
+	return fmt.Sprintf("%d из %d", left, limit)
+}
+
+// reserveConnect проверяет лимиты перед отправкой запроса на общение.
+// Если запрос отправлять нельзя, сообщает пользователю причину и возвращает false.
+func (h *UpdateHandler) reserveConnect(senderID, targetID int64) bool {
+	denial, wait, err := h.connectLimiter.Reserve(senderID, targetID)
+	if err != nil {
+		log.Printf("Error checking connect limits: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Ошибка при отправке запроса. Попробуйте позже."))
+		return false
+	}
+
+	switch denial {
+	case cache.DailyLimitReached:
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Вы исчерпали лимит запросов на общение на сегодня. Попробуйте завтра."))
+		return false
+	case cache.CooldownActive:
+		h.bot.Send(tgbotapi.NewMessage(senderID, fmt.Sprintf(
+			"Вы уже отправляли запрос этому пользователю. Повторить можно через %s.", formatAge(wait.Truncate(time.Minute)+time.Minute))))
+		return false
+	case cache.TooManyPending:
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Слишком много запросов ждут ответа. Дождитесь ответа на уже отправленные."))
+		return false
+	}
+	return true
+}
+
+// resolveConnect снимает запрос на общение из ожидающих ответа
+func (h *UpdateHandler) resolveConnect(senderID, targetID int64) {
+	if err := h.connectLimiter.Resolve(senderID, targetID); err != nil {
+		log.Printf("Error resolving connect request: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько пользователей показывать в списке "кому вы понравились"
+const likersLimit = 10
+
+// Сколько помнить, что взаимная симпатия пары уже обработана
+const matchTTL = 24 * time.Hour
+
+type LikeHandler interface {
+	LikeUser(telegramID, targetID int64)
+	PassUser(telegramID, targetID int64)
+	ShowLikers(telegramID int64)
+}
+
+// LikeUser отмечает, что пользователь понравился. Никто не получает уведомлений,
+// пока симпатия не станет взаимной; при взаимной симпатии открывается чат.
+func (h *UpdateHandler) LikeUser(telegramID, targetID int64) {
+	if telegramID == targetID {
+		return
+	}
+	if h.isBlocked(telegramID, targetID) {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пользователь недоступен для общения."))
+		return
+	}
+
+	changed, err := h.likeRepository.RecordLike(telegramID, targetID, true)
+	if err != nil {
+		log.Printf("Error recording like: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении отметки. Попробуйте позже."))
+		return
+	}
+	if !changed {
+		// Повторное нажатие: отметка уже есть, ничего не делаем повторно
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Вы уже отметили этого пользователя."))
+		return
+	}
+
+	mutual, err := h.likeRepository.IsMutualLike(telegramID, targetID)
+	if err != nil {
+		log.Printf("Error checking mutual like: %v", err)
+		return
+	}
+	if !mutual {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "❤️ Отмечено. Если симпатия окажется взаимной, мы сразу откроем чат."))
+		return
+	}
+
+	// Если оба отметили друг друга одновременно, оба запроса видят взаимную симпатию.
+	// Чат открывает только тот, кто первым занял ключ пары.
+	first, err := h.redisClient.TryAcquire(matchKey(telegramID, targetID), matchTTL)
+	if err != nil {
+		log.Printf("Error claiming match: %v", err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "🎉 Симпатия взаимна!"))
+	h.bot.Send(tgbotapi.NewMessage(targetID, "🎉 Симпатия взаимна!"))
+	h.StartChat(telegramID, strconv.FormatInt(targetID, 10))
+}
+
+// matchKey - ключ взаимной симпатии пары, одинаковый для обоих пользователей
+func matchKey(telegramID1, telegramID2 int64) string {
+	if telegramID1 > telegramID2 {
+		telegramID1, telegramID2 = telegramID2, telegramID1
+	}
+	return fmt.Sprintf("match:{%d}:%d", telegramID1, telegramID2)
+}
+
+// PassUser отмечает, что пользователь не интересен
+func (h *UpdateHandler) PassUser(telegramID, targetID int64) {
+	if _, err := h.likeRepository.RecordLike(telegramID, targetID, false); err != nil {
+		log.Printf("Error recording pass: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении отметки. Попробуйте позже."))
+		return
+	}
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "Пропущено."))
+}
+
+// ShowLikers показывает пользователей, которым вы понравились и которых вы еще не оценили
+func (h *UpdateHandler) ShowLikers(telegramID int64) {
+	likers, err := h.likeRepository.GetLikers(telegramID, likersLimit)
+	if err != nil {
+		log.Printf("Error getting likers: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении списка. Попробуйте позже."))
+		return
+	}
+	if len(likers) == 0 {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пока никто не отметил вас. Загляните позже!"))
+		return
+	}
+
+	h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Вы понравились пользователям: %d", len(likers))))
+	for _, liker := range likers {
+		photo, err := h.userRepository.GetUserPhoto(liker.TelegramID)
+		if err == nil && photo != "" {
+			h.bot.Send(tgbotapi.NewPhoto(telegramID, tgbotapi.FileID(photo)))
+		}
+
+		msg := tgbotapi.NewMessage(telegramID, fmt.Sprintf("Имя: %s\nВозраст: %d\nПол: %s\nО себе: %s",
+			liker.FirstName, liker.Age, liker.Gender, liker.Bio))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("❤️ Нравится", fmt.Sprintf("like_%d", liker.TelegramID)),
+				tgbotapi.NewInlineKeyboardButtonData("👎 Пропустить", fmt.Sprintf("pass_%d", liker.TelegramID)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Заблокировать", fmt.Sprintf("block_%d", liker.TelegramID)),
+			),
+		)
+		h.bot.Send(msg)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type LiveLocationHandler interface {
+	StartLiveLocation(message *tgbotapi.Message)
+	HandleLiveLocation(message *tgbotapi.Message)
+	StopLiveLocation(telegramID int64)
+}
+
+// StartLiveLocation начинает отслеживать трансляцию геопозиции, присланную для включения видимости
+func (h *UpdateHandler) StartLiveLocation(message *tgbotapi.Message) {
+	telegramID := message.Chat.ID
+	until := message.Time().Add(time.Duration(message.Location.LivePeriod) * time.Second)
+
+	err := h.redisClient.StartLiveLocation(telegramID, message.MessageID, until)
+	if err != nil {
+		log.Printf("Error starting live location: %v", err)
+		return
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Остановить трансляцию", "live_stop"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(telegramID, fmt.Sprintf(
+		"Ваша локация будет обновляться, пока идет трансляция геопозиции (до %s). После ее окончания видимость выключится автоматически.",
+		until.Format("15:04")))
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+// HandleLiveLocation обрабатывает обновление трансляции геопозиции (EditedMessage с Location)
+func (h *UpdateHandler) HandleLiveLocation(message *tgbotapi.Message) {
+	telegramID := message.Chat.ID
+
+	messageID, until, ok, err := h.redisClient.GetLiveLocation(telegramID)
+	if err != nil {
+		log.Printf("Error getting live location: %v", err)
+		return
+	}
+
+	// Обновления трансляций, которые мы не отслеживаем (или уже остановили), игнорируем
+	if !ok || messageID != message.MessageID {
+		return
+	}
+
+	// Telegram присылает последнее обновление без live_period, когда пользователь останавливает трансляцию
+	if message.Location.LivePeriod == 0 || time.Now().After(until) {
+		h.endLiveLocation(telegramID, "Трансляция геопозиции завершилась, видимость <b>выключена</b>.\nЧтобы снова появиться в поиске, используйте /toggle_visibility")
+		return
+	}
+
+	_, _, err = h.saveUserLocation(telegramID, message.Location.Latitude, message.Location.Longitude)
+	if err != nil {
+		log.Printf("Error updating live location: %v", err)
+	}
+}
+
+// StopLiveLocation останавливает отслеживание трансляции по кнопке пользователя
+func (h *UpdateHandler) StopLiveLocation(telegramID int64) {
+	h.endLiveLocation(telegramID, "Бот больше не отслеживает вашу геопозицию, видимость <b>выключена</b>.\nЧтобы прекратить трансляцию в Telegram, нажмите «Остановить трансляцию» в сообщении с геопозицией.")
+}
+
+// endLiveLocation выключает видимость пользователя после окончания трансляции
+func (h *UpdateHandler) endLiveLocation(telegramID int64, text string) {
+	if err := h.turnOffVisibility(telegramID); err != nil {
+		log.Printf("Error turning off visibility: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, turnOffVisibilityErrorText))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, text)
+	msg.ParseMode = "HTML"
+	h.bot.Send(msg)
+}
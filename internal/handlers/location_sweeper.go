@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/messaging"
+	"geo_match_bot/internal/repository"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// LocationSweeper периодически удаляет из гео-индекса пользователей,
+// чья локация давно не обновлялась, и выключает им видимость.
+// Также удаляет точные координаты, срок хранения которых истек,
+// и выключает видимость по окончании трансляции геопозиции.
+type LocationSweeper struct {
+	bot                *tgbotapi.BotAPI
+	db                 *sql.DB
+	userRepository     *repository.UserRepository
+	cache              *cache.MemcacheClient
+	geoIndex           geo.Index
+	redisClient        *cache.RedisClient
+	publisher          messaging.Publisher
+	locationRepository *repository.LocationRepository
+	interval           time.Duration
+	liveInterval       time.Duration
+	maxAge             time.Duration
+	rawRetention       time.Duration
+}
+
+func NewLocationSweeper(
+	bot *tgbotapi.BotAPI,
+	db *sql.DB,
+	userRepo *repository.UserRepository,
+	cache *cache.MemcacheClient,
+	geoIndex geo.Index,
+	redisClient *cache.RedisClient,
+	publisher messaging.Publisher,
+	locationRepo *repository.LocationRepository,
+	interval, liveInterval, maxAge, rawRetention time.Duration,
+) *LocationSweeper {
+	return &LocationSweeper{
+		bot:                bot,
+		db:                 db,
+		userRepository:     userRepo,
+		cache:              cache,
+		geoIndex:           geoIndex,
+		redisClient:        redisClient,
+		publisher:          publisher,
+		locationRepository: locationRepo,
+		interval:           interval,
+		liveInterval:       liveInterval,
+		maxAge:             maxAge,
+		rawRetention:       rawRetention,
+	}
+}
+
+// Run запускает очистку по таймеру. Блокирует вызывающую горутину.
+func (s *LocationSweeper) Run() {
+	// Локации, добавленные до появления времени обновления, получают его сейчас,
+	// иначе они никогда не будут удалены
+	if backfiller, ok := s.geoIndex.(interface{ BackfillLastSeen() error }); ok {
+		if err := backfiller.BackfillLastSeen(); err != nil {
+			log.Printf("Error backfilling location timestamps: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	// Окончание трансляций проверяем чаще, чтобы видимость выключалась вовремя
+	liveTicker := time.NewTicker(s.liveInterval)
+	defer liveTicker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-liveTicker.C:
+			s.ExpireLiveLocations()
+		}
+	}
+}
+
+// ExpireLiveLocations выключает видимость пользователям, чья трансляция геопозиции закончилась
+func (s *LocationSweeper) ExpireLiveLocations() {
+	expired, err := s.redisClient.ExpiredLiveLocations(time.Now())
+	if err != nil {
+		log.Printf("Error getting expired live locations: %v", err)
+		return
+	}
+
+	for _, telegramID := range expired {
+		stopped, err := s.redisClient.StopLiveLocation(telegramID)
+		if err != nil || !stopped {
+			// Трансляцию уже остановил обработчик обновлений или сам пользователь
+			continue
+		}
+
+		if err := s.geoIndex.Remove(telegramID); err != nil {
+			log.Printf("Error removing user location: %v", err)
+		}
+		s.turnOffVisibility(telegramID, "Трансляция геопозиции завершилась, видимость <b>выключена</b>.\nЧтобы снова появиться в поиске, используйте /toggle_visibility")
+	}
+}
+
+// Sweep выполняет один проход очистки
+func (s *LocationSweeper) Sweep() {
+	s.purgeRawLocations()
+
+	removed, err := s.geoIndex.RemoveStale(s.maxAge)
+	if err != nil {
+		log.Printf("Error removing stale locations: %v", err)
+		return
+	}
+
+	for _, telegramID := range removed {
+		// Уведомляем только тех, у кого видимость была включена
+		visibility, err := s.cache.Get(fmt.Sprintf("visibility:%d", telegramID))
+		if err != nil || visibility != "true" {
+			continue
+		}
+
+		s.turnOffVisibility(telegramID, fmt.Sprintf(
+			"Ваша геолокация не обновлялась больше %s, поэтому видимость <b>выключена</b>.\nЧтобы снова появиться в поиске, используйте /toggle_visibility",
+			formatAge(s.maxAge)))
+	}
+
+	if len(removed) > 0 {
+		log.Printf("Removed %d stale locations", len(removed))
+	}
+}
+
+// turnOffVisibility отмечает пользователя невидимым в одной транзакции с событием для Kafka и уведомляет его
+func (s *LocationSweeper) turnOffVisibility(telegramID int64, text string) {
+	err := messaging.PublishInTx(s.db, s.publisher, messaging.UserRemoveEvent{TelegramID: telegramID}, func(tx *sql.Tx) error {
+		return s.userRepository.SetUserVisibleTx(tx, telegramID, false)
+	})
+	if err != nil {
+		log.Printf("Error turning off visibility of %d: %v", telegramID, err)
+	}
+	s.cache.Set(fmt.Sprintf("visibility:%d", telegramID), "false")
+
+	msg := tgbotapi.NewMessage(telegramID, text)
+	msg.ParseMode = "HTML"
+	s.bot.Send(msg)
+}
+
+// purgeRawLocations удаляет точные координаты старше срока хранения.
+// Если срок не задан, новые координаты не сохраняются, а записанные раньше
+// (пока срок был задан) удаляются все.
+func (s *LocationSweeper) purgeRawLocations() {
+	cutoff := time.Now()
+	if s.rawRetention > 0 {
+		cutoff = cutoff.Add(-s.rawRetention)
+	}
+	deleted, err := s.locationRepository.DeleteLocationsOlderThan(cutoff)
+	if err != nil {
+		log.Printf("Error purging raw locations: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Purged %d raw locations", deleted)
+	}
+}
+
+// formatAge форматирует длительность для сообщения пользователю
+func formatAge(age time.Duration) string {
+	if age >= time.Hour {
+		return fmt.Sprintf("%d ч", int(age.Hours()))
+	}
+	return fmt.Sprintf("%d мин", int(age.Minutes()))
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"fmt"
 	"geo_match_bot/internal/fsm"
+	"geo_match_bot/internal/messaging"
 	"log"
 	"strconv"
 	"strings"
@@ -14,6 +15,13 @@ type MessageHandler interface {
 	HandleMessage(update tgbotapi.Update)
 }
 
+const (
+	locationRequestText        = "Отправьте свою геолокацию или напишите город и район (например: Москва, Арбат):"
+	locationNotRecognizedText  = "Не удалось определить место. Отправьте геолокацию или напишите город и район, например: Москва, Арбат."
+	turnOnVisibilityErrorText  = "Ошибка при включении видимости. Попробуйте позже."
+	turnOffVisibilityErrorText = "Не удалось выключить видимость. Попробуйте позже."
+)
+
 // Обработка сообщений (ответов на вопросы)
 func (h *UpdateHandler) HandleMessage(update tgbotapi.Update) {
 	telegramID := update.Message.Chat.ID
@@ -25,33 +33,42 @@ func (h *UpdateHandler) HandleMessage(update tgbotapi.Update) {
 
 	// Обработка состояния установки геолокации для видимости
 	if currentState == fsm.StepSetLocationForVisibility {
-		if update.Message.Location == nil {
-			h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, отправьте корректную геолокацию."))
+		latitude, longitude, placeName, ok := h.locationFromMessage(update.Message)
+		if !ok {
+			h.bot.Send(tgbotapi.NewMessage(telegramID, locationNotRecognizedText))
 			return
 		}
 
-		latitude := update.Message.Location.Latitude
-		longitude := update.Message.Location.Longitude
-
-		// Сохраняем локацию пользователя в Redis и включаем видимость
-		go h.redisClient.AddUserLocation(telegramID, latitude, longitude)
-		// if err != nil {
-		// 	log.Printf("Error saving user location in Redis: %v", err)
-		// 	h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении локации. Попробуйте позже."))
-		// 	return
-		// }
-
-		// Добавляем пользователя в sKafka
-		go h.kafkaProducer.Produce("geo-match-search", "user_visibility", fmt.Sprintf("%d,%f,%f", telegramID, latitude, longitude))
+		// Сохраняем огрубленную локацию пользователя в Redis и включаем видимость
+		latitude, longitude, err = h.saveUserLocation(telegramID, latitude, longitude)
+		if err != nil {
+			log.Printf("Error saving user location in Redis: %v", err)
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении локации. Попробуйте позже."))
+			return
+		}
 
-		// Устанавливаем видимость в кэше
-		go h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), "true")
+		// Сохраняем видимость вместе с событием для Kafka и обновляем кэш до ответа пользователю,
+		// чтобы не разойтись с последующим выключением
+		if err := h.setVisibility(telegramID, true, messaging.UserVisibilityEvent{TelegramID: telegramID, Latitude: latitude, Longitude: longitude}); err != nil {
+			log.Printf("Error turning on visibility: %v", err)
+			h.bot.Send(tgbotapi.NewMessage(telegramID, turnOnVisibilityErrorText))
+			return
+		}
 
 		// Завершаем установку и очищаем состояние FSM
 		h.fsm.ClearState(telegramID)
-		msg := tgbotapi.NewMessage(telegramID, "Видимость <b>включена</b>. Теперь вы доступны для поиска.")
+		txt := "Видимость <b>включена</b>. Теперь вы доступны для поиска."
+		if placeName != "" {
+			txt += fmt.Sprintf("\nВаше местоположение: %s", placeName)
+		}
+		msg := tgbotapi.NewMessage(telegramID, txt)
 		msg.ParseMode = "HTML"
 		h.bot.Send(msg)
+
+		// Если пользователь поделился трансляцией геопозиции, обновляем локацию до ее окончания
+		if update.Message.Location != nil && update.Message.Location.LivePeriod > 0 {
+			h.StartLiveLocation(update.Message)
+		}
 		return
 	}
 
@@ -64,6 +81,15 @@ func (h *UpdateHandler) HandleMessage(update tgbotapi.Update) {
 		return
 	}
 
+	if update.Message.Text == "Заблокировать собеседника" {
+		chatPartner, innerErr := h.cache.Get(fmt.Sprintf("chat:%d", telegramID))
+		if innerErr == nil && chatPartner != "" {
+			partnerID, _ := strconv.ParseInt(chatPartner, 10, 64)
+			h.BlockUser(telegramID, partnerID) // Блокировка завершает чат для обоих
+		}
+		return
+	}
+
 	// Проверяем, находится ли пользователь в чате
 	chatPartner, err := h.cache.Get(fmt.Sprintf("chat:%d", telegramID))
 	if err == nil && chatPartner != "" {
@@ -103,6 +129,12 @@ func (h *UpdateHandler) HandleMessage(update tgbotapi.Update) {
 		h.saveSearchAge(update)
 	case fsm.StepSearchLocation: // Добавляем шаг для обработки локации
 		h.saveSearchLocation(update)
+	case fsm.StepSavedSearchGender:
+		h.saveSavedSearchGender(update)
+	case fsm.StepSavedSearchAge:
+		h.saveSavedSearchAge(update)
+	case fsm.StepSavedSearchLocation:
+		h.saveSavedSearchLocation(update)
 	default:
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, начните с команды /start."))
 	}
@@ -279,22 +311,23 @@ func (h *UpdateHandler) saveSearchAge(update tgbotapi.Update) {
 
 	// Переходим к следующему шагу: запрос геолокации
 	h.fsm.SetState(telegramID, fsm.StepSearchLocation)
-	h.bot.Send(tgbotapi.NewMessage(telegramID, "Отправьте свою геолокацию:"))
+	h.bot.Send(tgbotapi.NewMessage(telegramID, locationRequestText))
 }
 
 func (h *UpdateHandler) saveSearchLocation(update tgbotapi.Update) {
 	telegramID := update.Message.Chat.ID
 
-	if update.Message.Location == nil {
-		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, отправьте корректную геолокацию."))
+	latitude, longitude, placeName, ok := h.locationFromMessage(update.Message)
+	if !ok {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, locationNotRecognizedText))
 		return
 	}
+	if placeName != "" {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Ищем рядом с: %s", placeName)))
+	}
 
-	latitude := update.Message.Location.Latitude
-	longitude := update.Message.Location.Longitude
-
-	// Сохраняем локацию пользователя в Redis
-	err := h.redisClient.AddUserLocation(telegramID, latitude, longitude)
+	// Сохраняем огрубленную локацию пользователя в Redis
+	latitude, longitude, err := h.saveUserLocation(telegramID, latitude, longitude)
 	if err != nil {
 		log.Printf("Error saving user location in Redis: %v", err)
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении локации. Попробуйте позже."))
@@ -304,3 +337,20 @@ func (h *UpdateHandler) saveSearchLocation(update tgbotapi.Update) {
 	// Отправляем запрос на поиск через Kafka
 	h.StartKafkaSearch(telegramID, latitude, longitude)
 }
+
+// locationFromMessage извлекает координаты из геолокации или из названия места, введенного текстом.
+// Для места из справочника возвращает также его название, чтобы показать пользователю.
+func (h *UpdateHandler) locationFromMessage(message *tgbotapi.Message) (float64, float64, string, bool) {
+	if message.Location != nil {
+		return message.Location.Latitude, message.Location.Longitude, "", true
+	}
+	if message.Text == "" {
+		return 0, 0, "", false
+	}
+
+	place, ok := h.gazetteer.Resolve(message.Text)
+	if !ok {
+		return 0, 0, "", false
+	}
+	return place.Latitude, place.Longitude, place.FullName(), true
+}
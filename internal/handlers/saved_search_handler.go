@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"fmt"
+	"geo_match_bot/internal/fsm"
+	"geo_match_bot/internal/repository"
+	"geo_match_bot/internal/search"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type SavedSearchHandler interface {
+	StartSaveSearch(telegramID int64)
+	ShowSavedSearches(telegramID int64)
+	DeleteSavedSearch(telegramID int64, searchID int)
+}
+
+// StartSaveSearch начинает сохранение поиска: пол, возраст, затем местоположение
+func (h *UpdateHandler) StartSaveSearch(telegramID int64) {
+	searches, err := h.savedSearchRepository.GetSavedSearches(telegramID)
+	if err != nil {
+		log.Printf("Error getting saved searches: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении сохраненных поисков. Попробуйте позже."))
+		return
+	}
+	if len(searches) >= h.savedSearchLimit {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf(
+			"Можно сохранить не больше %d поисков. Удалите ненужные: /saved_searches", h.savedSearchLimit)))
+		return
+	}
+
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "Кого вы хотите найти? Укажите пол (м/ж) или «любой»:"))
+	h.fsm.SetState(telegramID, fsm.StepSavedSearchGender)
+}
+
+func (h *UpdateHandler) saveSavedSearchGender(update tgbotapi.Update) {
+	telegramID := update.Message.Chat.ID
+	gender := strings.TrimSpace(update.Message.Text)
+
+	switch {
+	case strings.EqualFold(gender, "любой"):
+		gender = ""
+	case strings.EqualFold(gender, "м"), strings.EqualFold(gender, "ж"):
+		gender = strings.ToLower(gender)
+	default:
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, укажите 'м', 'ж' или 'любой'."))
+		return
+	}
+
+	h.cache.Set(fmt.Sprintf("saved_search_gender:%d", telegramID), gender)
+
+	h.fsm.SetState(telegramID, fsm.StepSavedSearchAge)
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "Укажите возрастной диапазон (например, 25-30) или «любой»:"))
+}
+
+func (h *UpdateHandler) saveSavedSearchAge(update tgbotapi.Update) {
+	telegramID := update.Message.Chat.ID
+	ageRange := strings.TrimSpace(update.Message.Text)
+
+	if strings.EqualFold(ageRange, "любой") {
+		ageRange = ""
+	} else if _, _, ok := parseAgeRange(ageRange); !ok {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, укажите диапазон от 14 до 80 лет, например 25-30, или «любой»."))
+		return
+	}
+
+	h.cache.Set(fmt.Sprintf("saved_search_age:%d", telegramID), ageRange)
+
+	h.fsm.SetState(telegramID, fsm.StepSavedSearchLocation)
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "Где искать? "+locationRequestText))
+}
+
+func (h *UpdateHandler) saveSavedSearchLocation(update tgbotapi.Update) {
+	telegramID := update.Message.Chat.ID
+
+	latitude, longitude, placeName, ok := h.locationFromMessage(update.Message)
+	if !ok {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, locationNotRecognizedText))
+		return
+	}
+
+	gender, _ := h.cache.Get(fmt.Sprintf("saved_search_gender:%d", telegramID))
+	ageRange, _ := h.cache.Get(fmt.Sprintf("saved_search_age:%d", telegramID))
+	ageMin, ageMax, _ := parseAgeRange(ageRange)
+
+	// Радиус, выбранный пользователем (0 - используем радиус по умолчанию)
+	preferredRadius, err := h.userRepository.GetUserSearchRadius(telegramID)
+	if err != nil {
+		log.Printf("Error getting search radius: %v", err)
+	}
+
+	// Центр поиска огрубляется так же, как локация пользователя
+	latitude, longitude = h.privacy.Obfuscate(telegramID, latitude, longitude)
+	savedSearch := repository.SavedSearch{
+		RadiusKm:  h.radiusPolicy.Start(preferredRadius),
+		Gender:    gender,
+		AgeMin:    ageMin,
+		AgeMax:    ageMax,
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+	err = h.savedSearchRepository.CreateSavedSearch(telegramID, savedSearch)
+	if err != nil {
+		log.Printf("Error saving search: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении поиска. Попробуйте позже."))
+		return
+	}
+
+	h.fsm.ClearState(telegramID)
+	txt := "Поиск сохранен: " + formatSavedSearch(savedSearch)
+	if placeName != "" {
+		txt += fmt.Sprintf(" рядом с: %s", placeName)
+	}
+	txt += ".\nМы сообщим, когда рядом появится подходящий пользователь. Список поисков: /saved_searches"
+	h.bot.Send(tgbotapi.NewMessage(telegramID, txt))
+}
+
+// ShowSavedSearches показывает сохраненные поиски с кнопками удаления
+func (h *UpdateHandler) ShowSavedSearches(telegramID int64) {
+	searches, err := h.savedSearchRepository.GetSavedSearches(telegramID)
+	if err != nil {
+		log.Printf("Error getting saved searches: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении сохраненных поисков. Попробуйте позже."))
+		return
+	}
+	if len(searches) == 0 {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "У вас нет сохраненных поисков. Сохранить поиск: /save_search"))
+		return
+	}
+
+	txt := "Сохраненные поиски:"
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, savedSearch := range searches {
+		txt += fmt.Sprintf("\n%d. %s", i+1, formatSavedSearch(savedSearch))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Удалить %d", i+1), fmt.Sprintf("saved_search_delete_%d", savedSearch.ID)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, txt)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.bot.Send(msg)
+}
+
+// DeleteSavedSearch удаляет сохраненный поиск пользователя
+func (h *UpdateHandler) DeleteSavedSearch(telegramID int64, searchID int) {
+	deleted, err := h.savedSearchRepository.DeleteSavedSearch(telegramID, searchID)
+	if err != nil {
+		log.Printf("Error deleting saved search: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при удалении поиска. Попробуйте позже."))
+		return
+	}
+	if !deleted {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Поиск уже удален."))
+		return
+	}
+	h.bot.Send(tgbotapi.NewMessage(telegramID, "Поиск удален."))
+}
+
+// parseAgeRange разбирает диапазон вида "25-30". Пустая строка - без ограничения.
+func parseAgeRange(ageRange string) (int, int, bool) {
+	if ageRange == "" {
+		return 0, 0, true
+	}
+
+	parts := strings.Split(ageRange, "-")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	ageMin, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	ageMax, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	if ageMin < 14 || ageMax > 80 || ageMin > ageMax {
+		return 0, 0, false
+	}
+	return ageMin, ageMax, true
+}
+
+// formatSavedSearch описывает сохраненный поиск одной строкой
+func formatSavedSearch(s repository.SavedSearch) string {
+	gender := "любой пол"
+	switch s.Gender {
+	case "м":
+		gender = "мужчины"
+	case "ж":
+		gender = "женщины"
+	}
+
+	age := "любой возраст"
+	if s.AgeMin > 0 || s.AgeMax > 0 {
+		age = fmt.Sprintf("%d-%d лет", s.AgeMin, s.AgeMax)
+	}
+
+	return fmt.Sprintf("%s, %s, в радиусе %s", gender, age, search.FormatRadius(s.RadiusKm))
+}
@@ -3,19 +3,27 @@ package handlers
 import (
 	"fmt"
 	"geo_match_bot/internal/fsm"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/messaging"
+	"geo_match_bot/internal/search"
 	"log"
 	"strconv"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// searchRequestTTL - сколько ждать результатов поиска от воркера
+const searchRequestTTL = 10 * time.Minute
+
 type SearchHandler interface {
 	StartSearchProcess(telegramID int64)
 	StartSearch(update tgbotapi.Update)
 	StartKafkaSearch(telegramID int64, latitude, longitude float64)
-	ShowNearbyUser(telegramID int64, userID string)
 	SendProfileToUser(senderID int64, targetUserID string)
 	SearchNextUser(telegramID int64)
+	ShowRadiusSettings(telegramID int64)
+	SetSearchRadius(telegramID int64, radiusKm float64)
 }
 
 func (h *UpdateHandler) StartSearchProcess(telegramID int64) {
@@ -24,7 +32,7 @@ func (h *UpdateHandler) StartSearchProcess(telegramID int64) {
 	h.bot.Send(msg)
 
 	// Запрашиваем у пользователя его местоположение (если не было запрошено ранее)
-	h.bot.Send(tgbotapi.NewMessage(telegramID, "Пожалуйста, отправьте свою геолокацию:"))
+	h.bot.Send(tgbotapi.NewMessage(telegramID, locationRequestText))
 	h.fsm.SetState(telegramID, fsm.StepSearchLocation)
 }
 func (h *UpdateHandler) StartSearch(update tgbotapi.Update) {
@@ -38,8 +46,16 @@ func (h *UpdateHandler) StartSearch(update tgbotapi.Update) {
 	h.fsm.SetState(telegramID, fsm.StepSearchGender)
 }
 func (h *UpdateHandler) StartKafkaSearch(telegramID int64, latitude, longitude float64) {
-	// Отправляем запрос на поиск через Kafka
-	err := h.kafkaProducer.Produce("geo-match-search", "user_search", fmt.Sprintf("%d,%f,%f", telegramID, latitude, longitude))
+	// Бот покажет результаты только последнего запроса пользователя
+	requestID := messaging.NewRequestID()
+	if err := h.redisClient.SetSearchRequest(telegramID, requestID, searchRequestTTL); err != nil {
+		log.Printf("Error saving search request: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при запуске поиска. Попробуйте позже."))
+		return
+	}
+
+	// Отправляем запрос на поиск через Kafka и ждем подтверждения, чтобы не обещать результаты впустую
+	err := h.publisher.PublishSync(messaging.UserSearchEvent{RequestID: requestID, TelegramID: telegramID, Latitude: latitude, Longitude: longitude})
 	if err != nil {
 		log.Printf("Error sending search request to Kafka: %v", err)
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при запуске поиска. Попробуйте позже."))
@@ -48,45 +64,34 @@ func (h *UpdateHandler) StartKafkaSearch(telegramID int64, latitude, longitude f
 
 	h.bot.Send(tgbotapi.NewMessage(telegramID, "Начат поиск пользователей поблизости... Ожидайте результатов."))
 }
-func (h *UpdateHandler) ShowNearbyUser(telegramID int64, userID string) {
-	// Получаем данные пользователя по его внутреннему ID
-	user, err := h.userRepository.GetUserByID(userID)
-	if err != nil || user == nil {
-		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении данных пользователя."))
+func (h *UpdateHandler) SendProfileToUser(senderID int64, targetUserID string) {
+	// Получаем данные пользователя, который хочет пообщаться
+	senderProfile, err := h.userRepository.GetUserByTelegramID(senderID)
+	if err != nil || senderProfile == nil {
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Ошибка при получении вашего профиля."))
 		return
 	}
 
-	// Формируем текст профиля
-	profileText := fmt.Sprintf("Имя: %s\nВозраст: %d\nПол: %s\nО себе: %s",
-		user.FirstName, user.Age, user.Gender, user.Bio)
-
-	msg := tgbotapi.NewMessage(telegramID, profileText)
-	h.bot.Send(msg)
-
-	// Получаем фото пользователя
-	photo, err := h.userRepository.GetUserPhotoByID(userID)
-	if err == nil && photo != "" {
-		photoMsg := tgbotapi.NewPhoto(telegramID, tgbotapi.FileID(photo))
-		h.bot.Send(photoMsg)
+	// Проверяем получателя до учета запроса в лимитах, чтобы некорректный запрос не расходовал их
+	targetUserIDInt, err := strconv.ParseInt(targetUserID, 10, 64)
+	if err != nil || targetUserIDInt == senderID {
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Некорректный запрос на общение."))
+		return
+	}
+	targetProfile, err := h.userRepository.GetUserByTelegramID(targetUserIDInt)
+	if err != nil || targetProfile == nil {
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Пользователь не найден."))
+		return
 	}
 
-	// Добавляем inline-кнопки "Предложить пообщаться" и "Искать дальше"
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Предложить пообщаться", fmt.Sprintf("connect_%s", userID)),
-			tgbotapi.NewInlineKeyboardButtonData("Искать дальше", "search_next"),
-		),
-	)
+	// Между заблокированными пользователями запросы не отправляются
+	if h.isBlocked(senderID, targetUserIDInt) {
+		h.bot.Send(tgbotapi.NewMessage(senderID, "Пользователь недоступен для общения."))
+		return
+	}
 
-	menuMsg := tgbotapi.NewMessage(telegramID, "Что вы хотите сделать?")
-	menuMsg.ReplyMarkup = keyboard
-	h.bot.Send(menuMsg)
-}
-func (h *UpdateHandler) SendProfileToUser(senderID int64, targetUserID string) {
-	// Получаем данные пользователя, который хочет пообщаться
-	senderProfile, err := h.userRepository.GetUserByTelegramID(senderID)
-	if err != nil || senderProfile == nil {
-		h.bot.Send(tgbotapi.NewMessage(senderID, "Ошибка при получении вашего профиля."))
+	// Дневной лимит, повторные запросы и запросы без ответа
+	if !h.reserveConnect(senderID, targetUserIDInt) {
 		return
 	}
 
@@ -94,9 +99,6 @@ func (h *UpdateHandler) SendProfileToUser(senderID int64, targetUserID string) {
 	profileText := fmt.Sprintf("Пользователь %s хочет с вами пообщаться:\nИмя: %s\nВозраст: %d\nПол: %s\nО себе: %s",
 		senderProfile.FirstName, senderProfile.FirstName, senderProfile.Age, senderProfile.Gender, senderProfile.Bio)
 
-	// Отправляем сообщение целевому пользователю
-	targetUserIDInt, _ := strconv.ParseInt(targetUserID, 10, 64)
-
 	// Получаем фото отправителя из репозитория
 	photo, err := h.userRepository.GetUserPhoto(senderID)
 	if err == nil && photo != "" {
@@ -115,32 +117,111 @@ func (h *UpdateHandler) SendProfileToUser(senderID int64, targetUserID string) {
 			tgbotapi.NewInlineKeyboardButtonData("Принять", fmt.Sprintf("accept_%d", senderID)),
 			tgbotapi.NewInlineKeyboardButtonData("Отказать", fmt.Sprintf("decline_%d", senderID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Заблокировать", fmt.Sprintf("block_%d", senderID)),
+		),
 	)
 
 	menuMsg := tgbotapi.NewMessage(targetUserIDInt, "Что вы хотите сделать?")
 	menuMsg.ReplyMarkup = keyboard
 	h.bot.Send(menuMsg)
+
+	// Сообщаем отправителю, сколько запросов у него осталось
+	txt := "Запрос отправлен."
+	if quota, err := h.connectLimiter.Quota(senderID); err == nil {
+		txt += fmt.Sprintf(" Осталось запросов сегодня: %s.", formatQuota(quota.DailyLeft, quota.DailyLimit))
+	}
+	h.bot.Send(tgbotapi.NewMessage(senderID, txt))
 }
 func (h *UpdateHandler) SearchNextUser(telegramID int64) {
-	// Получаем текущую локацию пользователя
-	latitude, longitude, err := h.redisClient.GetUserLocation(telegramID)
+	// Проверяем, что локация пользователя известна: поиск ведется от нее
+	_, _, err := h.geoIndex.Position(telegramID)
 	if err != nil {
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при получении вашей локации. Попробуйте позже."))
 		return
 	}
 
-	// Ищем пользователей поблизости в радиусе, скажем, 10 км
-	nearbyUsers, err := h.redisClient.FindNearbyUsers(telegramID, latitude, longitude, 10)
+	// Радиус, выбранный пользователем (0 - используем радиус по умолчанию)
+	preferredRadius, err := h.userRepository.GetUserSearchRadius(telegramID)
+	if err != nil {
+		log.Printf("Error getting search radius: %v", err)
+	}
+
+	// Заблокированные пользователи (в любую сторону) не попадают в поиск
+	blockedIDs, err := h.blockRepository.GetBlockedTelegramIDs(telegramID)
+	if err != nil {
+		log.Printf("Error getting blocked users: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при поиске пользователей. Попробуйте позже."))
+		return
+	}
+
+	// Ищем пользователей поблизости, расширяя радиус, если кандидатов мало
+	nearbyUsers, radius, err := search.Expand(h.radiusPolicy, preferredRadius, func(radiusKm float64) ([]geo.Neighbor, error) {
+		return h.geoIndex.Nearby(geo.Query{
+			FromUserID:     telegramID,
+			RadiusKm:       radiusKm,
+			Limit:          h.radiusPolicy.ResultLimit,
+			ExcludeUserID:  telegramID,
+			ExcludeUserIDs: blockedIDs,
+		})
+	})
 	if err != nil {
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при поиске пользователей. Попробуйте позже."))
 		return
 	}
 
 	if len(nearbyUsers) == 0 {
-		h.bot.Send(tgbotapi.NewMessage(telegramID, "Поблизости нет пользователей."))
+		h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("В радиусе %s нет пользователей.", search.FormatRadius(radius))))
+		return
+	}
+
+	h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Радиус поиска: %s", search.FormatRadius(radius))))
+
+	// Показываем найденных пользователей одним сообщением, начиная с ближайшего
+	h.carousel.Show(telegramID, nearbyUsers)
+}
+
+// ShowRadiusSettings показывает кнопки выбора радиуса поиска
+func (h *UpdateHandler) ShowRadiusSettings(telegramID int64) {
+	currentRadius, err := h.userRepository.GetUserSearchRadius(telegramID)
+	if err != nil {
+		log.Printf("Error getting search radius: %v", err)
+	}
+	currentRadius = h.radiusPolicy.Start(currentRadius)
+
+	// Кнопки по три в ряд
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, option := range h.radiusPolicy.Options() {
+		label := search.FormatRadius(option)
+		if option == currentRadius {
+			label = "✅ " + label
+		}
+		if i%3 == 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow())
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], tgbotapi.NewInlineKeyboardButtonData(label, "radius_"+strconv.FormatFloat(option, 'f', -1, 64)))
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, fmt.Sprintf(
+		"Текущий радиус поиска: %s\nЕсли рядом мало пользователей, радиус будет автоматически расширен до %s.\nВыберите радиус:",
+		search.FormatRadius(currentRadius), search.FormatRadius(h.radiusPolicy.MaxKm)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.bot.Send(msg)
+}
+
+// SetSearchRadius сохраняет выбранный пользователем радиус поиска
+func (h *UpdateHandler) SetSearchRadius(telegramID int64, radiusKm float64) {
+	if radiusKm <= 0 || radiusKm > h.radiusPolicy.MaxKm {
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Некорректный радиус."))
+		return
+	}
+
+	err := h.userRepository.UpdateUserSearchRadius(telegramID, radiusKm)
+	if err != nil {
+		log.Printf("Error updating search radius: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении радиуса. Попробуйте позже."))
 		return
 	}
 
-	// Показываем первого найденного пользователя
-	h.ShowNearbyUser(telegramID, nearbyUsers[0])
+	h.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Радиус поиска: %s", search.FormatRadius(radiusKm))))
 }
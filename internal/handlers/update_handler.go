@@ -1,40 +1,97 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/carousel"
 	"geo_match_bot/internal/fsm"
+	"geo_match_bot/internal/gazetteer"
+	"geo_match_bot/internal/geo"
 	"geo_match_bot/internal/messaging"
 	"geo_match_bot/internal/repository"
+	"geo_match_bot/internal/search"
 	"log"
+	"strconv"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type UpdateHandler struct {
 	bot            *tgbotapi.BotAPI
+	db             *sql.DB // Транзакции с изменениями данных и событиями о них
 	userRepository *repository.UserRepository
 	cache          *cache.MemcacheClient
 	fsm            *fsm.FSM
-	redisClient    *cache.RedisClient       // Добавляем RedisClient
-	kafkaProducer  *messaging.KafkaProducer // Добавляем KafkaProducer
+	geoIndex       geo.Index           // Гео-индекс видимых пользователей
+	redisClient    *cache.RedisClient  // Трансляции геопозиции
+	publisher      messaging.Publisher // Отправка событий поиска и видимости
+	radiusPolicy   search.RadiusPolicy // Политика радиуса поиска
+
+	// Приватность локаций
+	privacy            geo.Privacy
+	locationRepository *repository.LocationRepository
+	rawRetention       time.Duration // Сколько хранить точные координаты (0 - не хранить)
+
+	gazetteer *gazetteer.Gazetteer // Справочник мест для ввода местоположения текстом
+
+	savedSearchRepository *repository.SavedSearchRepository
+	savedSearchLimit      int // Сколько поисков может сохранить один пользователь
+
+	blockRepository *repository.BlockRepository
+	likeRepository  *repository.LikeRepository // Взаимные симпатии
+
+	connectLimiter *cache.ConnectLimiter // Лимиты запросов на общение
+	carousel       *carousel.Carousel    // Показ результатов поиска
 }
 
 func NewUpdateHandler(
 	bot *tgbotapi.BotAPI,
+	db *sql.DB,
 	userRepo *repository.UserRepository,
 	cache *cache.MemcacheClient,
-	redisClient *cache.RedisClient, // Добавляем RedisClient
-	kafkaProducer *messaging.KafkaProducer, // Добавляем KafkaProducer
+	geoIndex geo.Index, // Гео-индекс видимых пользователей
+	redisClient *cache.RedisClient,
+	publisher messaging.Publisher,
+	radiusPolicy search.RadiusPolicy,
+	privacy geo.Privacy,
+	locationRepo *repository.LocationRepository,
+	rawRetention time.Duration,
+	places *gazetteer.Gazetteer,
+	savedSearchRepo *repository.SavedSearchRepository,
+	savedSearchLimit int,
+	blockRepo *repository.BlockRepository,
+	likeRepo *repository.LikeRepository,
+	connectLimiter *cache.ConnectLimiter,
+	resultsCarousel *carousel.Carousel,
 ) func(update tgbotapi.Update) {
 	fsmHandler := fsm.NewFSM(cache)
 	handler := &UpdateHandler{
 		bot:            bot,
+		db:             db,
 		userRepository: userRepo,
 		cache:          cache,
 		fsm:            fsmHandler,
-		redisClient:    redisClient,   // Инициализация RedisClient
-		kafkaProducer:  kafkaProducer, // Инициализация KafkaProducer
+		geoIndex:       geoIndex,
+		redisClient:    redisClient,
+		publisher:      publisher,
+		radiusPolicy:   radiusPolicy,
+
+		privacy:            privacy,
+		locationRepository: locationRepo,
+		rawRetention:       rawRetention,
+
+		gazetteer: places,
+
+		savedSearchRepository: savedSearchRepo,
+		savedSearchLimit:      savedSearchLimit,
+
+		blockRepository: blockRepo,
+		likeRepository:  likeRepo,
+
+		connectLimiter: connectLimiter,
+		carousel:       resultsCarousel,
 	}
 	return handler.HandleUpdate
 }
@@ -45,6 +102,11 @@ func (h *UpdateHandler) HandleUpdate(update tgbotapi.Update) {
 		h.HandleCallbackQuery(update.CallbackQuery)
 		return
 	}
+	// Обновления трансляции геопозиции приходят как отредактированные сообщения
+	if update.EditedMessage != nil && update.EditedMessage.Location != nil {
+		h.HandleLiveLocation(update.EditedMessage)
+		return
+	}
 	// Обрабатываем команды (например, /start)
 	if update.Message != nil {
 		if update.Message.IsCommand() {
@@ -73,27 +135,31 @@ func (h *UpdateHandler) ToggleVisibility(update tgbotapi.Update, telegramID int6
 		newVisibility = "false"
 	}
 
-	// Обновляем статус видимости в кэше
-	h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), newVisibility)
-
 	// Обновляем данные в Redis и Kafka в зависимости от нового статуса
 	if newVisibility == "true" {
 		// Проверяем, есть ли геолокация пользователя
-		latitude, longitude, err := h.redisClient.GetUserLocation(telegramID)
+		latitude, longitude, err := h.geoIndex.Position(telegramID)
 		if err != nil || latitude == 0 || longitude == 0 {
 			// Если геолокации нет или она некорректна, запрашиваем у пользователя
-			h.bot.Send(tgbotapi.NewMessage(telegramID, "Включение видимости требует указания геолокации. Пожалуйста, отправьте свою геопозицию."))
+			h.bot.Send(tgbotapi.NewMessage(telegramID, "Включение видимости требует указания геолокации. "+locationRequestText))
 			h.fsm.SetState(telegramID, fsm.StepSetLocationForVisibility) // Состояние для получения геолокации
 			return
 		}
 
-		// Добавляем пользователя в Redis и Kafka
-		h.redisClient.AddUserLocation(telegramID, latitude, longitude)
-		h.kafkaProducer.Produce("geo-match-search", "user_visibility", fmt.Sprintf("%d,%f,%f", telegramID, latitude, longitude))
+		// Добавляем пользователя в гео-индекс и Kafka
+		h.geoIndex.Add(telegramID, latitude, longitude)
+		if err := h.setVisibility(telegramID, true, messaging.UserVisibilityEvent{TelegramID: telegramID, Latitude: latitude, Longitude: longitude}); err != nil {
+			log.Printf("Error turning on visibility: %v", err)
+			h.bot.Send(tgbotapi.NewMessage(telegramID, turnOnVisibilityErrorText))
+			return
+		}
 	} else {
-		// Удаляем пользователя из Redis и Kafka
-		h.redisClient.RemoveUserLocation(telegramID)
-		h.kafkaProducer.Produce("geo-match-search", "user_remove", fmt.Sprintf("%d", telegramID))
+		// Удаляем пользователя из гео-индекса и Kafka
+		if err := h.turnOffVisibility(telegramID); err != nil {
+			log.Printf("Error turning off visibility: %v", err)
+			h.bot.Send(tgbotapi.NewMessage(telegramID, turnOffVisibilityErrorText))
+			return
+		}
 	}
 
 	// Обновляем кнопки в главном меню
@@ -118,7 +184,7 @@ func (h *UpdateHandler) saveLocationForVisibility(update tgbotapi.Update) {
 	longitude := update.Message.Location.Longitude
 
 	// Сохраняем локацию пользователя в Redis
-	err := h.redisClient.AddUserLocation(telegramID, latitude, longitude)
+	latitude, longitude, err := h.saveUserLocation(telegramID, latitude, longitude)
 	if err != nil {
 		log.Printf("Error saving user location in Redis: %v", err)
 		h.bot.Send(tgbotapi.NewMessage(telegramID, "Ошибка при сохранении локации. Попробуйте позже."))
@@ -126,11 +192,56 @@ func (h *UpdateHandler) saveLocationForVisibility(update tgbotapi.Update) {
 	}
 
 	// Устанавливаем пользователя как видимого
-	h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), "true")
-	h.kafkaProducer.Produce("geo-match-search", "user_visibility", fmt.Sprintf("%d,%f,%f", telegramID, latitude, longitude))
+	if err := h.setVisibility(telegramID, true, messaging.UserVisibilityEvent{TelegramID: telegramID, Latitude: latitude, Longitude: longitude}); err != nil {
+		log.Printf("Error turning on visibility: %v", err)
+		h.bot.Send(tgbotapi.NewMessage(telegramID, turnOnVisibilityErrorText))
+		return
+	}
 
 	// Сообщаем пользователю об успешном включении видимости и возвращаем в главное меню
 	h.bot.Send(tgbotapi.NewMessage(telegramID, "Видимость успешно включена."))
 	h.ShowMainMenu(telegramID)
 	h.fsm.ClearState(telegramID)
 }
+
+// saveUserLocation огрубляет координаты, сохраняет их в гео-индексе и возвращает сохраненные значения.
+// Точные координаты пишутся в БД, только если задан срок их хранения.
+func (h *UpdateHandler) saveUserLocation(telegramID int64, latitude, longitude float64) (float64, float64, error) {
+	if h.rawRetention > 0 {
+		if err := h.locationRepository.SaveRawLocation(telegramID, latitude, longitude); err != nil {
+			log.Printf("Error saving raw location: %v", err)
+		}
+	}
+
+	latitude, longitude = h.privacy.Obfuscate(telegramID, latitude, longitude)
+	if err := h.geoIndex.Add(telegramID, latitude, longitude); err != nil {
+		return 0, 0, err
+	}
+
+	return latitude, longitude, nil
+}
+
+// turnOffVisibility убирает пользователя из поиска: прекращает отслеживание трансляции,
+// удаляет локацию из гео-индекса и сохраняет видимость вместе с событием для Kafka.
+// Возвращает ошибку, если видимость не сохранена: вызывающий сообщает о ней пользователю.
+func (h *UpdateHandler) turnOffVisibility(telegramID int64) error {
+	if _, err := h.redisClient.StopLiveLocation(telegramID); err != nil {
+		log.Printf("Error stopping live location: %v", err)
+	}
+	if err := h.geoIndex.Remove(telegramID); err != nil {
+		log.Printf("Error removing user location: %v", err)
+	}
+	return h.setVisibility(telegramID, false, messaging.UserRemoveEvent{TelegramID: telegramID})
+}
+
+// setVisibility сохраняет видимость пользователя в БД в одной транзакции с событием о ней
+// и обновляет кэш видимости
+func (h *UpdateHandler) setVisibility(telegramID int64, visible bool, event messaging.Event) error {
+	err := messaging.PublishInTx(h.db, h.publisher, event, func(tx *sql.Tx) error {
+		return h.userRepository.SetUserVisibleTx(tx, telegramID, visible)
+	})
+	if err != nil {
+		return fmt.Errorf("error saving visibility of %d: %v", telegramID, err)
+	}
+	return h.cache.Set(fmt.Sprintf("visibility:%d", telegramID), strconv.FormatBool(visible))
+}
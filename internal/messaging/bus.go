@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Ошибки чтения из Subscriber
+var (
+	ErrTimeout = errors.New("no message within timeout") // За время ожидания сообщений не было
+	ErrFatal   = errors.New("fatal subscriber error")    // Подписчик больше не может получать сообщения
+)
+
+// Заголовки сообщений с событиями
+const (
+	HeaderEventType = "event_type" // Тип события
+	HeaderEventID   = "event_id"   // ID события из конверта
+	// HeaderReplayConsumer - группа потребителей, для которой событие повторено из DeadLetterTopic.
+	// Остальные группы такое событие пропускают: они его уже обработали.
+	HeaderReplayConsumer = "replay_consumer"
+)
+
+// Message - сообщение шины
+type Message struct {
+	Topic   string
+	Key     []byte // Ключ партиции: ID пользователя, чтобы его события обрабатывались по порядку
+	Value   []byte
+	Headers map[string]string
+
+	Partition int32 // Партиция и позиция, из которых сообщение прочитано
+	Offset    int64
+}
+
+// Offset - позиция, с которой группа продолжит чтение партиции
+type Offset struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+// EventType возвращает тип события из заголовка, а для сообщений старого формата - из ключа
+func (m *Message) EventType() string {
+	if eventType, ok := m.Headers[HeaderEventType]; ok {
+		return eventType
+	}
+	return string(m.Key)
+}
+
+// Publisher отправляет сообщения в шину
+type Publisher interface {
+	// Produce ставит сообщение в очередь отправки, не дожидаясь доставки
+	Produce(msg *Message) error
+	// ProduceSync отправляет сообщение и ждет подтверждения доставки
+	ProduceSync(msg *Message) error
+	// Publish кодирует событие в конверт текущей версии и отправляет его в топик события, не дожидаясь доставки
+	Publish(event Event) error
+	// PublishSync - Publish с ожиданием подтверждения доставки
+	PublishSync(event Event) error
+	// Close дожидается отправки оставшихся сообщений; вызывается при остановке
+	Close() error
+}
+
+// Subscriber получает сообщения из шины в составе группы потребителей:
+// каждая группа получает каждое сообщение, внутри группы - один из участников
+type Subscriber interface {
+	// Subscribe подписывается на топик. onRevoke (может быть nil) вызывается из ReadMessage,
+	// когда группа забирает у участника партиции: к его возврату обработка прочитанных
+	// сообщений должна быть завершена и закоммичена, иначе их получит другой участник.
+	Subscribe(topic string, onRevoke func()) error
+	// ReadMessage ждет сообщение не дольше timeout (отрицательный timeout - без ограничения).
+	// Возвращает ErrTimeout, если сообщений не было, и ошибку, оборачивающую ErrFatal,
+	// если продолжать чтение бессмысленно.
+	ReadMessage(timeout time.Duration) (*Message, error)
+	// Commit сохраняет позиции группы. Автоматических коммитов нет: что не закоммичено,
+	// будет прочитано снова после перезапуска или перебалансировки.
+	Commit(offsets []Offset) error
+	Close() error
+}
+
+// eventMessage кодирует событие в конверт текущей версии и собирает сообщение для топика события.
+// Ключ - ID пользователя, тип и ID события передаются в заголовках и в самом конверте.
+func eventMessage(event Event) (*Message, error) {
+	data, meta, err := Codec{}.Encode(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event.EventType(), err)
+		return nil, err
+	}
+	return &Message{
+		Topic:   eventTopic(event),
+		Key:     []byte(event.PartitionKey()),
+		Value:   data,
+		Headers: map[string]string{HeaderEventType: meta.Type, HeaderEventID: meta.ID},
+	}, nil
+}
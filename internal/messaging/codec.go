@@ -0,0 +1,199 @@
+package messaging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventVersion - текущая версия схемы событий
+const EventVersion = 1
+
+// ErrMalformedEvent - сообщение не удалось разобрать; такие сообщения пропускаются
+var ErrMalformedEvent = errors.New("malformed event")
+
+// envelope - JSON-представление события в Kafka
+type envelope struct {
+	Version   int             `json:"version"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Codec кодирует события в JSON-конверт с версией, ID и временем создания
+// и разбирает их обратно, отклоняя некорректные сообщения.
+// Пока не все отправители обновлены, может разбирать и старый формат CSV.
+type Codec struct {
+	AcceptLegacy bool // Принимать события в старом формате "telegramID,latitude,longitude"
+}
+
+// NewCodec создает кодек событий
+func NewCodec(acceptLegacy bool) Codec {
+	return Codec{AcceptLegacy: acceptLegacy}
+}
+
+// Encode упаковывает событие в конверт текущей версии
+func (c Codec) Encode(event Event) ([]byte, Metadata, error) {
+	if err := event.Validate(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	meta := Metadata{
+		Version:   EventVersion,
+		ID:        newEventID(),
+		Type:      event.EventType(),
+		Timestamp: time.Now().UTC(),
+	}
+	data, err := json.Marshal(envelope{
+		Version:   meta.Version,
+		ID:        meta.ID,
+		Type:      meta.Type,
+		Timestamp: meta.Timestamp,
+		Payload:   payload,
+	})
+	return data, meta, err
+}
+
+// Decode разбирает сообщение. В старом формате тип события передавался в ключе сообщения.
+// Ошибки разбора оборачивают ErrMalformedEvent.
+func (c Codec) Decode(msg *Message) (Metadata, Event, error) {
+	value := bytes.TrimSpace(msg.Value)
+	if len(value) > 0 && value[0] == '{' {
+		return c.decodeEnvelope(value)
+	}
+	if !c.AcceptLegacy {
+		return Metadata{}, nil, fmt.Errorf("%w: not a JSON envelope", ErrMalformedEvent)
+	}
+	return decodeLegacy(msg.EventType(), string(value))
+}
+
+func (c Codec) decodeEnvelope(value []byte) (Metadata, Event, error) {
+	// Неизвестные поля пропускаются: добавление поля в текущую версию не должно
+	// отправлять события в DLQ у потребителей, которые еще не обновлены
+	var env envelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return Metadata{}, nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+
+	switch {
+	case env.Version < 1 || env.Version > EventVersion:
+		return Metadata{}, nil, fmt.Errorf("%w: unsupported version %d", ErrMalformedEvent, env.Version)
+	case env.ID == "":
+		return Metadata{}, nil, fmt.Errorf("%w: missing event id", ErrMalformedEvent)
+	case env.Timestamp.IsZero():
+		return Metadata{}, nil, fmt.Errorf("%w: missing timestamp", ErrMalformedEvent)
+	case len(env.Payload) == 0:
+		return Metadata{}, nil, fmt.Errorf("%w: missing payload", ErrMalformedEvent)
+	}
+
+	event, err := newEvent(env.Type)
+	if err != nil {
+		return Metadata{}, nil, err
+	}
+	if err := json.Unmarshal(env.Payload, event); err != nil {
+		return Metadata{}, nil, fmt.Errorf("%w: invalid %s payload: %v", ErrMalformedEvent, env.Type, err)
+	}
+
+	meta := Metadata{Version: env.Version, ID: env.ID, Type: env.Type, Timestamp: env.Timestamp}
+	return validated(meta, event)
+}
+
+// newEvent возвращает указатель на пустое событие заданного типа
+func newEvent(eventType string) (Event, error) {
+	switch eventType {
+	case EventUserSearch:
+		return &UserSearchEvent{}, nil
+	case EventUserVisibility:
+		return &UserVisibilityEvent{}, nil
+	case EventUserRemove:
+		return &UserRemoveEvent{}, nil
+	case EventSearchResults:
+		return &SearchResultsEvent{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown event type %q", ErrMalformedEvent, eventType)
+	}
+}
+
+// validated разыменовывает событие и проверяет его поля
+func validated(meta Metadata, event Event) (Metadata, Event, error) {
+	switch e := event.(type) {
+	case *UserSearchEvent:
+		event = *e
+	case *UserVisibilityEvent:
+		event = *e
+	case *UserRemoveEvent:
+		event = *e
+	case *SearchResultsEvent:
+		event = *e
+	}
+	if err := event.Validate(); err != nil {
+		return Metadata{}, nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+	return meta, event, nil
+}
+
+// decodeLegacy разбирает старый формат: тип в ключе, "telegramID,latitude,longitude" или "telegramID" в значении
+func decodeLegacy(eventType, value string) (Metadata, Event, error) {
+	meta := Metadata{Type: eventType}
+	parts := strings.Split(value, ",")
+
+	switch eventType {
+	case EventUserSearch, EventUserVisibility:
+		if len(parts) != 3 {
+			return Metadata{}, nil, fmt.Errorf("%w: expected 3 fields, got %d", ErrMalformedEvent, len(parts))
+		}
+		telegramID, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("%w: invalid telegram id: %v", ErrMalformedEvent, err)
+		}
+		latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("%w: invalid latitude: %v", ErrMalformedEvent, err)
+		}
+		longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("%w: invalid longitude: %v", ErrMalformedEvent, err)
+		}
+		if eventType == EventUserSearch {
+			return validated(meta, UserSearchEvent{TelegramID: telegramID, Latitude: latitude, Longitude: longitude})
+		}
+		return validated(meta, UserVisibilityEvent{TelegramID: telegramID, Latitude: latitude, Longitude: longitude})
+	case EventUserRemove:
+		if len(parts) != 1 {
+			return Metadata{}, nil, fmt.Errorf("%w: expected 1 field, got %d", ErrMalformedEvent, len(parts))
+		}
+		telegramID, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return Metadata{}, nil, fmt.Errorf("%w: invalid telegram id: %v", ErrMalformedEvent, err)
+		}
+		return validated(meta, UserRemoveEvent{TelegramID: telegramID})
+	default:
+		return Metadata{}, nil, fmt.Errorf("%w: unknown event type %q", ErrMalformedEvent, eventType)
+	}
+}
+
+// newEventID возвращает случайный ID события в формате UUID v4
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand не должен отказывать; на всякий случай ID из времени
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
@@ -0,0 +1,110 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DeadLetterTopic - топик для событий, которые не удалось обработать
+const DeadLetterTopic = "geo-match-search-dlq"
+
+// DeadLetter - исходное сообщение и причина, по которой его не удалось обработать
+type DeadLetter struct {
+	Topic    string            `json:"topic"`             // Исходный топик
+	Key      string            `json:"key"`               // Исходный ключ
+	Value    string            `json:"value"`             // Исходное значение без изменений
+	Headers  map[string]string `json:"headers,omitempty"` // Исходные заголовки
+	Error    string            `json:"error"`             // Ошибка последней попытки
+	Attempts int               `json:"attempts"`          // Сколько раз пытались обработать
+	Consumer string            `json:"consumer"`          // Группа потребителей, которая не смогла обработать событие
+	FailedAt time.Time         `json:"failed_at"`
+}
+
+// OutboxConsumer - отправитель в DeadLetter для сообщений, которые outbox так и не смог отправить в шину
+const OutboxConsumer = "outbox"
+
+// DeadLetterQueue отправляет необработанные сообщения группы потребителей в DeadLetterTopic
+type DeadLetterQueue struct {
+	publisher Publisher
+	consumer  string
+}
+
+func NewDeadLetterQueue(publisher Publisher, consumer string) *DeadLetterQueue {
+	return &DeadLetterQueue{publisher: publisher, consumer: consumer}
+}
+
+// Send отправляет сообщение в DeadLetterTopic и ждет подтверждения.
+// Если и это не удалось, сообщение остается только в логе.
+func (q *DeadLetterQueue) Send(msg *Message, cause error, attempts int) {
+	data, err := json.Marshal(DeadLetter{
+		Topic:    msg.Topic,
+		Key:      string(msg.Key),
+		Value:    string(msg.Value),
+		Headers:  msg.Headers,
+		Error:    cause.Error(),
+		Attempts: attempts,
+		Consumer: q.consumer,
+		FailedAt: time.Now().UTC(),
+	})
+	if err == nil {
+		err = q.publisher.ProduceSync(&Message{Topic: DeadLetterTopic, Key: msg.Key, Value: data, Headers: msg.Headers})
+	}
+	if err != nil {
+		log.Printf("Failed to dead-letter %s event %q: %v", msg.EventType(), msg.Value, err)
+	}
+}
+
+// ReplayedForOther сообщает, что сообщение повторено из DeadLetterTopic для другой группы потребителей
+// и этой группе его обрабатывать не нужно
+func (q *DeadLetterQueue) ReplayedForOther(msg *Message) bool {
+	consumer, ok := msg.Headers[HeaderReplayConsumer]
+	return ok && consumer != q.consumer
+}
+
+// ReplayDeadLetters отправляет сообщения из DeadLetterTopic обратно в исходные топики.
+// Останавливается, когда новых сообщений нет дольше idle. Возвращает число отправленных сообщений.
+// Повторно отправленное событие обработает только группа, которая не смогла его обработать:
+// остальные группы пропускают его по заголовку HeaderReplayConsumer.
+func ReplayDeadLetters(subscriber Subscriber, publisher Publisher, idle time.Duration) (int, error) {
+	if err := subscriber.Subscribe(DeadLetterTopic, nil); err != nil {
+		return 0, fmt.Errorf("error subscribing to %s: %v", DeadLetterTopic, err)
+	}
+
+	replayed := 0
+	for {
+		msg, err := subscriber.ReadMessage(idle)
+		if errors.Is(err, ErrTimeout) {
+			return replayed, nil
+		}
+		if err != nil {
+			return replayed, err
+		}
+
+		var letter DeadLetter
+		if err := json.Unmarshal(msg.Value, &letter); err != nil || letter.Topic == "" || letter.Consumer == "" {
+			log.Printf("Skipping malformed dead letter %q: %v", msg.Value, err)
+		} else {
+			headers := make(map[string]string, len(letter.Headers)+1)
+			for name, value := range letter.Headers {
+				headers[name] = value
+			}
+			// Событие, которое не удалось отправить из outbox, не получила ни одна группа
+			if letter.Consumer != OutboxConsumer {
+				headers[HeaderReplayConsumer] = letter.Consumer
+			}
+			replay := &Message{Topic: letter.Topic, Key: []byte(letter.Key), Value: []byte(letter.Value), Headers: headers}
+			if err := publisher.ProduceSync(replay); err != nil {
+				return replayed, fmt.Errorf("error replaying %s event to %s: %v", replay.EventType(), letter.Topic, err)
+			}
+			replayed++
+		}
+
+		// Коммитим после отправки: при ошибке сообщение будет повторено при следующем запуске
+		if err := subscriber.Commit([]Offset{{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset + 1}}); err != nil {
+			return replayed, fmt.Errorf("error committing dead letter offset: %v", err)
+		}
+	}
+}
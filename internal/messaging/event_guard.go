@@ -0,0 +1,101 @@
+package messaging
+
+import (
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/config"
+	"log"
+	"time"
+)
+
+// EventGuard отсеивает события, которые группа потребителей уже обработала (по ID события),
+// и изменения состояния пользователя, которые старше уже примененного (по времени события).
+// Событие отмечается обработанным только после успешной обработки: если потребитель упадет
+// посреди обработки, повторно доставленное событие будет обработано снова, когда истечет аренда.
+// События старого формата без ID и времени пропускаются без проверок.
+type EventGuard struct {
+	redisClient *cache.RedisClient
+	group       string
+	ttl         time.Duration // Сколько помнить обработанные события и время последнего примененного
+	lease       time.Duration // Сколько событие считается обрабатываемым, пока не вызван Done или Release
+}
+
+func NewEventGuard(redisClient *cache.RedisClient, group string, cfg *config.Config) *EventGuard {
+	return &EventGuard{redisClient: redisClient, group: group, ttl: cfg.EventDedupTTL, lease: cfg.EventProcessingLease}
+}
+
+// Claim берет событие в обработку на время аренды. Возвращает false, если событие уже обработано
+// или его сейчас обрабатывает другой потребитель группы. После обработки нужно вызвать Done,
+// а если обработать не удалось - Release, чтобы событие можно было повторить.
+func (g *EventGuard) Claim(meta Metadata) bool {
+	if meta.ID == "" {
+		return true
+	}
+	claimed, err := g.redisClient.TryStart(g.processedKey(meta.ID), g.processingKey(meta.ID), g.lease)
+	if err != nil {
+		// Без Redis лучше обработать событие дважды, чем потерять
+		log.Printf("Error checking event %s: %v", meta.ID, err)
+		return true
+	}
+	return claimed
+}
+
+// Done отмечает событие обработанным и снимает аренду. Вызывается до коммита позиции сообщения.
+func (g *EventGuard) Done(meta Metadata) {
+	if meta.ID == "" {
+		return
+	}
+	if err := g.redisClient.Finish(g.processedKey(meta.ID), g.processingKey(meta.ID), g.ttl); err != nil {
+		log.Printf("Error marking event %s processed: %v", meta.ID, err)
+	}
+}
+
+// Release снимает аренду, не отмечая событие обработанным
+func (g *EventGuard) Release(meta Metadata) {
+	if meta.ID == "" {
+		return
+	}
+	if err := g.redisClient.Release(g.processingKey(meta.ID)); err != nil {
+		log.Printf("Error releasing event %s: %v", meta.ID, err)
+	}
+}
+
+// Fresh проверяет, что событие, меняющее состояние пользователя, не старше последнего примененного,
+// и запоминает его время. Повторная обработка того же события допускается. Если время последнего
+// примененного события уже забыто (прошло больше ttl), событие считается свежим: например,
+// повторенное из DeadLetterTopic после долгого простоя.
+func (g *EventGuard) Fresh(meta Metadata, telegramID int64) bool {
+	if meta.Timestamp.IsZero() {
+		return true
+	}
+
+	fresh, err := g.redisClient.Advance(fmt.Sprintf("event_applied:%s:%d", g.group, telegramID), meta.Timestamp, g.ttl)
+	if err != nil {
+		log.Printf("Error checking event %s order: %v", meta.ID, err)
+		return true
+	}
+	return fresh
+}
+
+// Ключи события лежат под одним hash tag: Claim и Done работают с обоими одной операцией,
+// что в Redis Cluster возможно только для ключей из одного слота
+func (g *EventGuard) processedKey(eventID string) string {
+	return fmt.Sprintf("event:{%s:%s}:processed", g.group, eventID)
+}
+
+func (g *EventGuard) processingKey(eventID string) string {
+	return fmt.Sprintf("event:{%s:%s}:processing", g.group, eventID)
+}
+
+// stateChangeUser возвращает пользователя, состояние которого меняет событие.
+// Запросы поиска состояние не меняют.
+func stateChangeUser(event Event) (int64, bool) {
+	switch e := event.(type) {
+	case UserVisibilityEvent:
+		return e.TelegramID, true
+	case UserRemoveEvent:
+		return e.TelegramID, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Топики событий
+const (
+	SearchTopic        = "geo-match-search"         // Запросы поиска, включение и выключение видимости
+	SearchResultsTopic = "geo-match-search-results" // Результаты поиска от воркеров для бота
+)
+
+// Типы событий
+const (
+	EventUserSearch     = "user_search"     // Пользователь запустил поиск
+	EventUserVisibility = "user_visibility" // Пользователь включил видимость
+	EventUserRemove     = "user_remove"     // Пользователь выключил видимость
+	EventSearchResults  = "search_results"  // Воркер нашел пользователей по запросу поиска
+)
+
+// Event - событие, которое можно отправить в Kafka
+type Event interface {
+	EventType() string
+	// PartitionKey - ключ партиции: события одного пользователя попадают в одну партицию и не переупорядочиваются
+	PartitionKey() string
+	Validate() error
+}
+
+// Metadata - общие поля конверта события
+type Metadata struct {
+	Version   int       // Версия схемы (0 - событие в старом формате CSV)
+	ID        string    // Уникальный ID события (пустой для старого формата)
+	Type      string    // Тип события
+	Timestamp time.Time // Когда событие создано (нулевое для старого формата)
+}
+
+// UserSearchEvent - пользователь запустил поиск от точки
+type UserSearchEvent struct {
+	RequestID  string  `json:"request_id,omitempty"` // Возвращается в SearchResultsEvent (пустой у старых отправителей)
+	TelegramID int64   `json:"telegram_id"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+}
+
+func (UserSearchEvent) EventType() string { return EventUserSearch }
+
+func (e UserSearchEvent) PartitionKey() string { return strconv.FormatInt(e.TelegramID, 10) }
+
+func (e UserSearchEvent) Validate() error {
+	return validateLocation(e.TelegramID, e.Latitude, e.Longitude)
+}
+
+// UserVisibilityEvent - пользователь включил видимость в точке (координаты уже огрублены)
+type UserVisibilityEvent struct {
+	TelegramID int64   `json:"telegram_id"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+}
+
+func (UserVisibilityEvent) EventType() string { return EventUserVisibility }
+
+func (e UserVisibilityEvent) PartitionKey() string { return strconv.FormatInt(e.TelegramID, 10) }
+
+func (e UserVisibilityEvent) Validate() error {
+	return validateLocation(e.TelegramID, e.Latitude, e.Longitude)
+}
+
+// UserRemoveEvent - пользователь выключил видимость
+type UserRemoveEvent struct {
+	TelegramID int64 `json:"telegram_id"`
+}
+
+func (UserRemoveEvent) EventType() string { return EventUserRemove }
+
+func (e UserRemoveEvent) PartitionKey() string { return strconv.FormatInt(e.TelegramID, 10) }
+
+func (e UserRemoveEvent) Validate() error {
+	if e.TelegramID <= 0 {
+		return fmt.Errorf("invalid telegram id %d", e.TelegramID)
+	}
+	return nil
+}
+
+// SearchResult - найденный пользователь
+type SearchResult struct {
+	TelegramID int64   `json:"telegram_id"`
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// SearchResultsEvent - результаты поиска по UserSearchEvent с тем же RequestID
+type SearchResultsEvent struct {
+	RequestID  string         `json:"request_id,omitempty"`
+	TelegramID int64          `json:"telegram_id"` // Кто искал
+	RadiusKm   float64        `json:"radius_km"`   // Радиус, до которого расширился поиск
+	Results    []SearchResult `json:"results"`     // От ближайших к дальним
+	Failed     bool           `json:"failed"`      // Поиск не удался, результатов нет
+}
+
+func (SearchResultsEvent) EventType() string { return EventSearchResults }
+
+func (e SearchResultsEvent) PartitionKey() string { return strconv.FormatInt(e.TelegramID, 10) }
+
+func (e SearchResultsEvent) Validate() error {
+	if e.TelegramID <= 0 {
+		return fmt.Errorf("invalid telegram id %d", e.TelegramID)
+	}
+	if e.RadiusKm < 0 {
+		return fmt.Errorf("invalid radius %f", e.RadiusKm)
+	}
+	return nil
+}
+
+// NewRequestID возвращает новый ID запроса поиска
+func NewRequestID() string {
+	return newEventID()
+}
+
+// eventTopic возвращает топик, в который отправляется событие
+func eventTopic(event Event) string {
+	if event.EventType() == EventSearchResults {
+		return SearchResultsTopic
+	}
+	return SearchTopic
+}
+
+func validateLocation(telegramID int64, latitude, longitude float64) error {
+	if telegramID <= 0 {
+		return fmt.Errorf("invalid telegram id %d", telegramID)
+	}
+	if latitude < -90 || latitude > 90 {
+		return fmt.Errorf("invalid latitude %f", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid longitude %f", longitude)
+	}
+	return nil
+}
@@ -2,175 +2,232 @@ package messaging
 
 import (
 	"fmt"
-	"geo_match_bot/internal/cache"
-	"geo_match_bot/internal/repository"
 	"log"
-	"strconv"
-	"strings"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sync/atomic"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
+// KafkaProducer - Publisher поверх Kafka. Produce не ждет подтверждения брокера:
+// отчеты о доставке читаются в отдельной горутине, ошибки логируются и учитываются в Stats.
 type KafkaProducer struct {
-	producer *kafka.Producer
+	producer     *kafka.Producer
+	flushTimeout time.Duration // Сколько ждать доставки оставшихся сообщений при остановке
+	delivered    atomic.Uint64
+	failed       atomic.Uint64
+	done         chan struct{} // Закрывается, когда горутина отчетов о доставке завершилась
 }
 
-type KafkaConsumer struct {
-	consumer       *kafka.Consumer
-	redisClient    *cache.RedisClient // Redis для поиска по геолокации
-	bot            *tgbotapi.BotAPI   // Telegram Bot для отправки сообщений
-	userRepository *repository.UserRepository
+// DeliveryStats - счетчики отчетов о доставке
+type DeliveryStats struct {
+	Delivered uint64
+	Failed    uint64
 }
 
 // NewKafkaProducer создает новый продюсер Kafka
-func NewKafkaProducer(broker string) (*KafkaProducer, error) {
+func NewKafkaProducer(broker string, flushTimeout time.Duration) (*KafkaProducer, error) {
 	p, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": broker,
+		"bootstrap.servers":  broker,
+		"enable.idempotence": true, // Повторные отправки не дублируют и не переупорядочивают события пользователя
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &KafkaProducer{producer: p}, nil
+	kp := &KafkaProducer{producer: p, flushTimeout: flushTimeout, done: make(chan struct{})}
+	go kp.handleDeliveryReports()
+	return kp, nil
 }
 
-// Produce отправляет сообщение в Kafka
-func (kp *KafkaProducer) Produce(topic string, key string, value string) error {
-	err := kp.producer.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-		Key:            []byte(key),
-		Value:          []byte(value),
-	}, nil)
+// handleDeliveryReports читает отчеты о доставке асинхронно отправленных сообщений
+func (kp *KafkaProducer) handleDeliveryReports() {
+	defer close(kp.done)
+
+	for e := range kp.producer.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			kp.countDelivery(ev)
+		case kafka.Error:
+			log.Printf("Kafka producer error: %v", ev)
+		}
+	}
+}
+
+// countDelivery учитывает отчет о доставке и возвращает ошибку доставки
+func (kp *KafkaProducer) countDelivery(msg *kafka.Message) error {
+	if err := msg.TopicPartition.Error; err != nil {
+		failed := kp.failed.Add(1)
+		log.Printf("Failed to deliver message to %s (key %q): %v (failed total: %d)", msg.TopicPartition, msg.Key, err, failed)
+		return err
+	}
+	kp.delivered.Add(1)
+	return nil
+}
 
+// Produce ставит сообщение в очередь отправки и не ждет доставки.
+// Ошибка возвращается, только если сообщение не удалось поставить в очередь.
+func (kp *KafkaProducer) Produce(msg *Message) error {
+	err := kp.producer.Produce(newKafkaMessage(msg), nil)
 	if err != nil {
 		log.Println("Failed to produce message:", err)
 		return err
 	}
-
-	kp.producer.Flush(15 * 1000)
 	return nil
 }
 
-func NewKafkaConsumer(broker, groupID string, redisClient *cache.RedisClient, bot *tgbotapi.BotAPI, userRepo *repository.UserRepository) (*KafkaConsumer, error) {
-	c, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": broker,
-		"group.id":          groupID,
-		"auto.offset.reset": "earliest",
-	})
-
+// ProduceSync отправляет сообщение и ждет подтверждения брокера
+func (kp *KafkaProducer) ProduceSync(msg *Message) error {
+	deliveryChan := make(chan kafka.Event, 1)
+	err := kp.producer.Produce(newKafkaMessage(msg), deliveryChan)
 	if err != nil {
-		return nil, err
+		log.Println("Failed to produce message:", err)
+		return err
 	}
 
-	return &KafkaConsumer{
-		consumer:       c,
-		redisClient:    redisClient,
-		bot:            bot,
-		userRepository: userRepo, 
-	}, nil
+	report, ok := (<-deliveryChan).(*kafka.Message)
+	if !ok {
+		return fmt.Errorf("unexpected delivery report for %s", msg.Topic)
+	}
+	return kp.countDelivery(report)
 }
 
-// Subscribe подписывается на топик и начинает получать сообщения
-func (kc *KafkaConsumer) Subscribe(topic string) error {
-	err := kc.consumer.Subscribe(topic, nil)
+// Publish кодирует событие в конверт текущей версии и отправляет его в топик события, не дожидаясь доставки
+func (kp *KafkaProducer) Publish(event Event) error {
+	msg, err := eventMessage(event)
 	if err != nil {
 		return err
 	}
+	return kp.Produce(msg)
+}
 
-	for {
-		msg, err := kc.consumer.ReadMessage(-1)
-		if err == nil {
-			fmt.Printf("Received message: %s\n", string(msg.Value))
-		} else {
-			fmt.Printf("Consumer error: %v\n", err)
-		}
+// PublishSync кодирует событие и ждет подтверждения его доставки
+func (kp *KafkaProducer) PublishSync(event Event) error {
+	msg, err := eventMessage(event)
+	if err != nil {
+		return err
+	}
+	return kp.ProduceSync(msg)
+}
+
+// Stats возвращает число доставленных и недоставленных сообщений
+func (kp *KafkaProducer) Stats() DeliveryStats {
+	return DeliveryStats{Delivered: kp.delivered.Load(), Failed: kp.failed.Load()}
+}
+
+// Close дожидается доставки оставшихся сообщений (не дольше flushTimeout) и закрывает продюсер
+func (kp *KafkaProducer) Close() error {
+	remaining := kp.producer.Flush(int(kp.flushTimeout.Milliseconds()))
+	kp.producer.Close()
+	<-kp.done
+
+	stats := kp.Stats()
+	log.Printf("Kafka producer closed: delivered %d, failed %d, undelivered %d", stats.Delivered, stats.Failed, remaining)
+	if remaining > 0 {
+		return fmt.Errorf("%d messages were not delivered before shutdown", remaining)
+	}
+	return nil
+}
+
+func newKafkaMessage(msg *Message) *kafka.Message {
+	topic := msg.Topic
+	kafkaMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+	}
+	for key, value := range msg.Headers {
+		kafkaMsg.Headers = append(kafkaMsg.Headers, kafka.Header{Key: key, Value: []byte(value)})
 	}
+	return kafkaMsg
+}
+
+// KafkaSubscriber - Subscriber поверх потребителя Kafka
+type KafkaSubscriber struct {
+	consumer *kafka.Consumer
 }
 
-// HandleSearchRequests обрабатывает поисковые запросы
-func (kc *KafkaConsumer) HandleSearchRequests() {
-	err := kc.consumer.Subscribe("geo-match-search", nil)
+// NewKafkaSubscriber создает потребителя Kafka в группе groupID с ручными коммитами.
+// offsetReset - с чего начинать чтение новой группе: "latest" или "earliest".
+func NewKafkaSubscriber(broker, groupID, offsetReset string) (*KafkaSubscriber, error) {
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  broker,
+		"group.id":           groupID,
+		"auto.offset.reset":  offsetReset,
+		"enable.auto.commit": false,
+	})
 	if err != nil {
-		log.Fatalf("Error subscribing to geo-match-search: %v", err)
-	}
-	return
-	for {
-		msg, err := kc.consumer.ReadMessage(-1)
-		if err != nil {
-			log.Printf("Error reading message: %v", err)
-			continue
-		}
+		return nil, err
+	}
+
+	return &KafkaSubscriber{consumer: c}, nil
+}
 
-		// Обрабатываем запрос на поиск
-		parts := strings.Split(string(msg.Value), ",")
-		telegramID, _ := strconv.ParseInt(parts[0], 10, 64)
-		latitude, _ := strconv.ParseFloat(parts[1], 64)
-		longitude, _ := strconv.ParseFloat(parts[2], 64)
-
-		// Ищем пользователей в Redis через redisClient
-		nearbyUsers, err := kc.redisClient.FindNearbyUsers(telegramID, latitude, longitude, 3.0) // Радиус поиска 3 км
-		if err != nil {
-			log.Printf("Error finding nearby users: %v", err)
-			continue
+// Subscribe подписывается на топик. Назначение и отзыв партиций выполняет библиотека
+// после вызова onRevoke.
+func (ks *KafkaSubscriber) Subscribe(topic string, onRevoke func()) error {
+	return ks.consumer.Subscribe(topic, func(_ *kafka.Consumer, ev kafka.Event) error {
+		switch e := ev.(type) {
+		case kafka.AssignedPartitions:
+			log.Printf("Assigned partitions: %v", e.Partitions)
+		case kafka.RevokedPartitions:
+			log.Printf("Revoked partitions: %v", e.Partitions)
+			if onRevoke != nil {
+				onRevoke()
+			}
 		}
+		return nil
+	})
+}
 
-		// Отправляем найденных пользователей обратно в бот
-		kc.SendSearchResults(telegramID, nearbyUsers)
+// Commit синхронно сохраняет позиции группы
+func (ks *KafkaSubscriber) Commit(offsets []Offset) error {
+	partitions := make([]kafka.TopicPartition, 0, len(offsets))
+	for _, offset := range offsets {
+		topic := offset.Topic
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: offset.Partition, Offset: kafka.Offset(offset.Offset)})
 	}
+	_, err := ks.consumer.CommitOffsets(partitions)
+	return err
 }
 
-// SendSearchResults отправляет результаты поиска пользователю
-func (kc *KafkaConsumer) SendSearchResults(telegramID int64, nearbyUsers []string) {
-	if len(nearbyUsers) == 0 {
-		// Если пользователей не найдено, отправляем уведомление
-		kc.bot.Send(tgbotapi.NewMessage(telegramID, "К сожалению, поблизости не найдено пользователей для общения. Попробуйте позже."))
-		return
+// ReadMessage читает следующее сообщение, приводя ошибки Kafka к ErrTimeout и ErrFatal
+func (ks *KafkaSubscriber) ReadMessage(timeout time.Duration) (*Message, error) {
+	msg, err := ks.consumer.ReadMessage(timeout)
+	if err != nil {
+		if kafkaErr, ok := err.(kafka.Error); ok {
+			if kafkaErr.Code() == kafka.ErrTimedOut {
+				return nil, ErrTimeout
+			}
+			if kafkaErr.IsFatal() {
+				return nil, fmt.Errorf("%w: %v", ErrFatal, err)
+			}
+		}
+		return nil, err
 	}
 
-	// Для каждого найденного пользователя вызываем метод показа профиля
-	for _, nearbyUserID := range nearbyUsers {
-		kc.SendProfileToUser(telegramID, nearbyUserID)
+	var topic string
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+	var headers map[string]string
+	if len(msg.Headers) > 0 {
+		headers = make(map[string]string, len(msg.Headers))
+		for _, header := range msg.Headers {
+			headers[header.Key] = string(header.Value)
+		}
 	}
+	return &Message{
+		Topic:     topic,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   headers,
+		Partition: msg.TopicPartition.Partition,
+		Offset:    int64(msg.TopicPartition.Offset),
+	}, nil
 }
 
-// SendProfileToUser отправляет профиль найденного пользователя в бота
-func (kc *KafkaConsumer) SendProfileToUser(requesterTelegramID int64, targetUserID string) {
-	targetUserIDInt, err := strconv.Atoi(targetUserID)
-	if err != nil {
-		log.Println(err)
-	}
-	// Получаем данные пользователя через репозиторий (или кеш)
-	user, err := kc.userRepository.GetUserByTelegramID(int64(targetUserIDInt))
-	if err != nil || user == nil {
-		kc.bot.Send(tgbotapi.NewMessage(requesterTelegramID, "Ошибка при получении данных профиля пользователя."))
-		return
-	}
-	// Формируем текст профиля
-	profileText := fmt.Sprintf("Имя: %s\nВозраст: %d\nПол: %s\nО себе: %s",
-		user.FirstName, user.Age, user.Gender, user.Bio)
-
-	// Отправляем текст профиля
-	msg := tgbotapi.NewMessage(requesterTelegramID, profileText)
-	kc.bot.Send(msg)
-
-	// Получаем фото пользователя
-	photo, err := kc.userRepository.GetUserPhoto(int64(targetUserIDInt))
-	if err == nil && photo != "" {
-		photoMsg := tgbotapi.NewPhoto(requesterTelegramID, tgbotapi.FileID(photo))
-		kc.bot.Send(photoMsg)
-	}
-
-	// Добавляем inline-кнопки для взаимодействия
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Предложить пообщаться", fmt.Sprintf("connect_%s", targetUserID)),
-			tgbotapi.NewInlineKeyboardButtonData("Искать дальше", "search_next"),
-		),
-	)
-
-	menuMsg := tgbotapi.NewMessage(requesterTelegramID, "Что вы хотите сделать?")
-	menuMsg.ReplyMarkup = keyboard
-	kc.bot.Send(menuMsg)
+// Close выходит из группы потребителей
+func (ks *KafkaSubscriber) Close() error {
+	return ks.consumer.Close()
 }
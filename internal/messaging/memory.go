@@ -0,0 +1,240 @@
+package messaging
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBus - шина сообщений в памяти процесса для запуска без брокера.
+// Семантика доставки как у Kafka: каждая группа потребителей получает каждое сообщение
+// топика, отправленное после ее подписки, порядок сообщений сохраняется,
+// а отправитель никогда не блокируется. Доставка "хотя бы один раз": сообщения,
+// прочитанные участником группы, но не закоммиченные до его Close, возвращаются в очередь группы.
+type MemoryBus struct {
+	mu            sync.Mutex
+	queues        map[string]*memoryQueue        // groupID -> очередь группы
+	subscriptions map[string]map[string]struct{} // topic -> группы, подписанные на топик
+}
+
+// NewMemoryBus создает пустую шину в памяти
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		queues:        make(map[string]*memoryQueue),
+		subscriptions: make(map[string]map[string]struct{}),
+	}
+}
+
+// Produce кладет сообщение в очереди всех групп, подписанных на топик.
+// Если подписчиков нет, сообщение теряется, как при auto.offset.reset=latest.
+func (b *MemoryBus) Produce(msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for groupID := range b.subscriptions[msg.Topic] {
+		b.queues[groupID].push(msg)
+	}
+	return nil
+}
+
+// ProduceSync совпадает с Produce: сообщение доставлено, как только попало в очереди групп
+func (b *MemoryBus) ProduceSync(msg *Message) error {
+	return b.Produce(msg)
+}
+
+// Publish кодирует событие в конверт текущей версии и отправляет его в топик события
+func (b *MemoryBus) Publish(event Event) error {
+	msg, err := eventMessage(event)
+	if err != nil {
+		return err
+	}
+	return b.Produce(msg)
+}
+
+// PublishSync совпадает с Publish
+func (b *MemoryBus) PublishSync(event Event) error {
+	return b.Publish(event)
+}
+
+// Close ничего не делает: неотправленных сообщений у шины в памяти не бывает
+func (b *MemoryBus) Close() error {
+	return nil
+}
+
+// Subscriber создает участника группы потребителей groupID.
+// Участники одной группы делят общую очередь, поэтому каждое сообщение получает один из них.
+func (b *MemoryBus) Subscriber(groupID string) *MemorySubscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queue, ok := b.queues[groupID]
+	if !ok {
+		queue = newMemoryQueue()
+		b.queues[groupID] = queue
+	}
+	return &MemorySubscriber{bus: b, groupID: groupID, queue: queue, closed: make(chan struct{})}
+}
+
+func (b *MemoryBus) subscribe(topic, groupID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscriptions[topic] == nil {
+		b.subscriptions[topic] = make(map[string]struct{})
+	}
+	b.subscriptions[topic][groupID] = struct{}{}
+}
+
+// MemorySubscriber - участник группы потребителей MemoryBus
+type MemorySubscriber struct {
+	bus        *MemoryBus
+	groupID    string
+	queue      *memoryQueue
+	subscribed bool
+	closed     chan struct{}
+	closeOnce  sync.Once
+
+	mu       sync.Mutex
+	inFlight []*Message // Прочитанные, но еще не закоммиченные сообщения
+}
+
+// Subscribe подписывает группу на топик. Перебалансировок в памяти нет, onRevoke не вызывается.
+func (s *MemorySubscriber) Subscribe(topic string, onRevoke func()) error {
+	s.bus.subscribe(topic, s.groupID)
+	s.subscribed = true
+	return nil
+}
+
+// ReadMessage возвращает следующее сообщение группы
+func (s *MemorySubscriber) ReadMessage(timeout time.Duration) (*Message, error) {
+	if !s.subscribed {
+		return nil, errors.New("not subscribed to any topic")
+	}
+
+	var deadline <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		if msg, ok := s.queue.pop(); ok {
+			s.mu.Lock()
+			s.inFlight = append(s.inFlight, msg)
+			s.mu.Unlock()
+			return msg, nil
+		}
+
+		select {
+		case <-s.queue.notify:
+		case <-deadline:
+			return nil, ErrTimeout
+		case <-s.closed:
+			return nil, ErrFatal
+		}
+	}
+}
+
+// Commit подтверждает обработку прочитанных сообщений топика с позицией меньше закоммиченной
+func (s *MemorySubscriber) Commit(offsets []Offset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remaining := s.inFlight[:0]
+	for _, msg := range s.inFlight {
+		if !committed(msg, offsets) {
+			remaining = append(remaining, msg)
+		}
+	}
+	for i := len(remaining); i < len(s.inFlight); i++ {
+		s.inFlight[i] = nil
+	}
+	s.inFlight = remaining
+	return nil
+}
+
+func committed(msg *Message, offsets []Offset) bool {
+	for _, offset := range offsets {
+		if offset.Topic == msg.Topic && msg.Offset < offset.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// Close прекращает чтение: ожидающий ReadMessage вернет ErrFatal,
+// а незакоммиченные сообщения возвращаются в очередь группы для повторной доставки
+func (s *MemorySubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+
+		s.mu.Lock()
+		s.queue.requeue(s.inFlight)
+		s.inFlight = nil
+		s.mu.Unlock()
+	})
+	return nil
+}
+
+// memoryQueue - неограниченная очередь сообщений одной группы
+type memoryQueue struct {
+	mu       sync.Mutex
+	messages []*Message
+	offset   int64         // Позиция следующего сообщения
+	notify   chan struct{} // Сигнал о новом сообщении
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{notify: make(chan struct{}, 1)}
+}
+
+func (q *memoryQueue) push(msg *Message) {
+	// У каждой группы своя копия сообщения со своей позицией
+	queued := *msg
+	q.mu.Lock()
+	queued.Offset = q.offset
+	q.offset++
+	q.messages = append(q.messages, &queued)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *memoryQueue) pop() (*Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.messages) == 0 {
+		return nil, false
+	}
+	msg := q.messages[0]
+	q.messages[0] = nil
+	q.messages = q.messages[1:]
+	return msg, true
+}
+
+// requeue возвращает сообщения в начало очереди, сохраняя порядок позиций
+func (q *memoryQueue) requeue(messages []*Message) {
+	if len(messages) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	merged := make([]*Message, 0, len(messages)+len(q.messages))
+	merged = append(merged, messages...)
+	merged = append(merged, q.messages...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Offset < merged[j].Offset
+	})
+	q.messages = merged
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,199 @@
+package messaging
+
+import (
+	"database/sql"
+	"fmt"
+	"geo_match_bot/internal/config"
+	"geo_match_bot/internal/repository"
+	"log"
+	"time"
+)
+
+// Outbox - Publisher, который не отправляет сообщения сразу, а пишет их в таблицу outbox.
+// Run пересылает записанные сообщения в шину и отмечает их отправленными: доставка at-least-once,
+// а ID события остается тем же при повторных отправках.
+type Outbox struct {
+	outboxRepository *repository.OutboxRepository
+	publisher        Publisher        // Шина, в которую пересылаются сообщения
+	interval         time.Duration    // Как часто проверять неотправленные сообщения
+	batchSize        uint64           // Сколько сообщений забирать за раз
+	retention        time.Duration    // Сколько хранить отправленные сообщения
+	lease            time.Duration    // На сколько воркер забирает сообщения для отправки
+	maxAttempts      int              // После стольких неудачных отправок сообщение откладывается
+	deadLetters      *DeadLetterQueue // Куда отправляются отложенные сообщения
+	wake             chan struct{}    // Сигнал о новом сообщении, чтобы не ждать interval
+}
+
+func NewOutbox(outboxRepo *repository.OutboxRepository, publisher Publisher, cfg *config.Config) *Outbox {
+	return &Outbox{
+		outboxRepository: outboxRepo,
+		publisher:        publisher,
+		interval:         cfg.OutboxRelayInterval,
+		batchSize:        uint64(cfg.OutboxBatchSize),
+		retention:        cfg.OutboxRetention,
+		lease:            cfg.OutboxLease,
+		maxAttempts:      cfg.OutboxMaxAttempts,
+		deadLetters:      NewDeadLetterQueue(publisher, OutboxConsumer),
+		wake:             make(chan struct{}, 1),
+	}
+}
+
+// Produce записывает сообщение в outbox отдельно от изменений данных: для событий,
+// которые ничего не меняют в БД (например, запрос поиска). Изменения данных
+// вместе с событием о них записывает PublishInTx.
+func (o *Outbox) Produce(msg *Message) error {
+	if err := o.outboxRepository.Enqueue(outboxMessage(msg)); err != nil {
+		log.Printf("Failed to write message to outbox: %v", err)
+		return err
+	}
+	o.notify()
+	return nil
+}
+
+// ProduceSync совпадает с Produce: после записи в outbox сообщение уже не потеряется
+func (o *Outbox) ProduceSync(msg *Message) error {
+	return o.Produce(msg)
+}
+
+// Publish кодирует событие и записывает его в outbox
+func (o *Outbox) Publish(event Event) error {
+	msg, err := eventMessage(event)
+	if err != nil {
+		return err
+	}
+	return o.Produce(msg)
+}
+
+// PublishSync совпадает с Publish
+func (o *Outbox) PublishSync(event Event) error {
+	return o.Publish(event)
+}
+
+// PublishInTx выполняет change и записывает событие о нем в outbox в одной транзакции:
+// событие будет отправлено, только если изменения зафиксированы, и наоборот.
+// Если publisher - не Outbox (outbox выключен), событие отправляется сразу после фиксации,
+// и при сбое между ними может потеряться.
+func PublishInTx(db *sql.DB, publisher Publisher, event Event, change func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := change(tx); err != nil {
+		return err
+	}
+
+	outbox, transactional := publisher.(*Outbox)
+	if transactional {
+		msg, err := eventMessage(event)
+		if err != nil {
+			return err
+		}
+		if err := outbox.outboxRepository.EnqueueTx(tx, outboxMessage(msg)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if !transactional {
+		return publisher.PublishSync(event)
+	}
+	outbox.notify()
+	return nil
+}
+
+// Close отправляет накопившиеся сообщения перед остановкой. Что не удалось отправить,
+// останется в outbox до следующего запуска.
+func (o *Outbox) Close() error {
+	return o.relay()
+}
+
+// Run пересылает сообщения из outbox в шину. Блокирует вызывающую горутину.
+func (o *Outbox) Run() {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.relay(); err != nil {
+			log.Printf("Error relaying outbox messages: %v", err)
+		}
+		if o.retention > 0 {
+			if _, err := o.outboxRepository.DeleteSent(o.retention); err != nil {
+				log.Printf("Error deleting sent outbox messages: %v", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// relay отправляет неотправленные сообщения пачками, пока они не закончатся или не случится ошибка.
+// Сообщения забираются отдельным запросом и отправляются вне транзакции. На первой ошибке
+// отправка прекращается, а оставшиеся сообщения возвращаются в очередь: брокер, скорее всего,
+// недоступен, и более поздние события того же пользователя не должны обогнать неотправленное.
+// Сообщение, которое не удалось отправить maxAttempts раз, откладывается, чтобы не задерживать очередь,
+// и отправляется в DeadLetterTopic.
+func (o *Outbox) relay() error {
+	for {
+		messages, err := o.outboxRepository.ClaimPending(o.batchSize, o.lease)
+		if err != nil {
+			return err
+		}
+
+		for i, msg := range messages {
+			sendErr := o.publisher.ProduceSync(&Message{Topic: msg.Topic, Key: []byte(msg.Key), Value: msg.Value, Headers: msg.Headers})
+			if sendErr == nil {
+				if err := o.outboxRepository.MarkSent(msg.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			exhausted, err := o.outboxRepository.MarkFailed(msg.ID, sendErr, msg.Attempts, o.maxAttempts)
+			if err != nil {
+				return err
+			}
+			if exhausted {
+				log.Printf("Giving up on outbox message %d (event %s) after %d attempts: %v", msg.ID, msg.EventID, o.maxAttempts, sendErr)
+				o.deadLetters.Send(&Message{Topic: msg.Topic, Key: []byte(msg.Key), Value: msg.Value, Headers: msg.Headers}, sendErr, o.maxAttempts)
+			}
+
+			unsent := make([]int64, 0, len(messages)-i-1)
+			for _, rest := range messages[i+1:] {
+				unsent = append(unsent, rest.ID)
+			}
+			if err := o.outboxRepository.Release(unsent); err != nil {
+				return err
+			}
+			return fmt.Errorf("error sending outbox message %d: %v", msg.ID, sendErr)
+		}
+
+		if len(messages) < int(o.batchSize) {
+			return nil
+		}
+	}
+}
+
+// notify будит Run, не блокируясь
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func outboxMessage(msg *Message) repository.OutboxMessage {
+	return repository.OutboxMessage{
+		EventID: msg.Headers[HeaderEventID],
+		Topic:   msg.Topic,
+		Key:     string(msg.Key),
+		Value:   msg.Value,
+		Headers: msg.Headers,
+	}
+}
@@ -0,0 +1,43 @@
+package messaging
+
+import (
+	"errors"
+	"geo_match_bot/internal/config"
+	"time"
+)
+
+// RetryPolicy - повторы обработки события с экспоненциальной задержкой
+type RetryPolicy struct {
+	MaxAttempts    int           // Сколько раз всего пытаться обработать событие
+	InitialBackoff time.Duration // Задержка перед первым повтором, дальше удваивается
+	MaxBackoff     time.Duration // Максимальная задержка между повторами
+}
+
+// NewRetryPolicy создает политику повторов из конфигурации
+func NewRetryPolicy(cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    cfg.EventMaxAttempts,
+		InitialBackoff: cfg.EventRetryBackoff,
+		MaxBackoff:     cfg.EventRetryMaxBackoff,
+	}
+}
+
+// Do вызывает fn, пока она не выполнится успешно или не кончатся попытки.
+// Некорректные события (ErrMalformedEvent) не повторяются.
+// Возвращает число сделанных попыток и последнюю ошибку.
+func (p RetryPolicy) Do(fn func() error) (int, error) {
+	backoff := p.InitialBackoff
+	attempt := 1
+	for ; ; attempt++ {
+		err := fn()
+		if err == nil || errors.Is(err, ErrMalformedEvent) || attempt >= p.MaxAttempts {
+			return attempt, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/repository"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SavedSearchConsumer читает события user_visibility в отдельной группе потребителей
+// и уведомляет владельцев сохраненных поисков о подходящих пользователях поблизости
+type SavedSearchConsumer struct {
+	pool                  *WorkerPool
+	bot                   *tgbotapi.BotAPI
+	userRepository        *repository.UserRepository
+	savedSearchRepository *repository.SavedSearchRepository
+	redisClient           *cache.RedisClient // Ограничение частоты уведомлений
+	privacy               geo.Privacy        // Округление показываемых расстояний
+	cooldown              time.Duration      // Не чаще одного уведомления владельцу за этот интервал
+	repeatAfter           time.Duration      // Повторно об одном и том же пользователе - не раньше
+	codec                 Codec              // Разбор событий
+	retry                 RetryPolicy        // Повторы при ошибках обработки
+	deadLetters           *DeadLetterQueue   // Куда отправлять события, которые не удалось обработать
+	guard                 *EventGuard        // Повторные и устаревшие события
+}
+
+func NewSavedSearchConsumer(
+	pool *WorkerPool,
+	bot *tgbotapi.BotAPI,
+	userRepo *repository.UserRepository,
+	savedSearchRepo *repository.SavedSearchRepository,
+	redisClient *cache.RedisClient,
+	privacy geo.Privacy,
+	cooldown, repeatAfter time.Duration,
+	codec Codec,
+	retry RetryPolicy,
+	deadLetters *DeadLetterQueue,
+	guard *EventGuard,
+) *SavedSearchConsumer {
+	return &SavedSearchConsumer{
+		pool:                  pool,
+		bot:                   bot,
+		userRepository:        userRepo,
+		savedSearchRepository: savedSearchRepo,
+		redisClient:           redisClient,
+		privacy:               privacy,
+		cooldown:              cooldown,
+		repeatAfter:           repeatAfter,
+		codec:                 codec,
+		retry:                 retry,
+		deadLetters:           deadLetters,
+		guard:                 guard,
+	}
+}
+
+// Run подписывается на SearchTopic и обрабатывает события видимости в пуле воркеров.
+// Блокирует вызывающую горутину до отмены ctx.
+func (sc *SavedSearchConsumer) Run(ctx context.Context) error {
+	return sc.pool.Run(ctx, SearchTopic, sc.process)
+}
+
+// process обрабатывает событие видимости с повторами; если обработать не удалось,
+// отправляет его в DeadLetterTopic. Повторные события и события видимости,
+// после которых пользователь уже выключил видимость, пропускаются.
+func (sc *SavedSearchConsumer) process(msg *Message) {
+	if sc.deadLetters.ReplayedForOther(msg) {
+		return
+	}
+
+	meta, event, err := sc.codec.Decode(msg)
+	if err != nil {
+		log.Printf("Skipping event %q: %v", msg.Value, err)
+		sc.deadLetters.Send(msg, err, 1)
+		return
+	}
+
+	// Выключение видимости только запоминаем, чтобы не уведомлять по более старым событиям
+	userID, ok := stateChangeUser(event)
+	if !ok {
+		return
+	}
+	if !sc.guard.Claim(meta) {
+		log.Printf("Skipping %s event %s: already processed or in progress", meta.Type, meta.ID)
+		return
+	}
+	if !sc.guard.Fresh(meta, userID) {
+		log.Printf("Skipping outdated %s event %s for %d", meta.Type, meta.ID, userID)
+		sc.guard.Done(meta)
+		return
+	}
+
+	visibility, ok := event.(UserVisibilityEvent)
+	if !ok {
+		sc.guard.Done(meta)
+		return
+	}
+	attempts, err := sc.retry.Do(func() error {
+		return sc.HandleVisibility(visibility.TelegramID, visibility.Latitude, visibility.Longitude)
+	})
+	if err != nil {
+		log.Printf("Error handling %s event %q after %d attempts: %v", msg.EventType(), msg.Value, attempts, err)
+		sc.guard.Release(meta)
+		sc.deadLetters.Send(msg, err, attempts)
+		return
+	}
+	sc.guard.Done(meta)
+}
+
+// HandleVisibility находит сохраненные поиски, которым подходит ставший видимым пользователь,
+// и отправляет уведомления их владельцам. Уже отправленные уведомления не повторяются при повторной обработке.
+func (sc *SavedSearchConsumer) HandleVisibility(telegramID int64, latitude, longitude float64) error {
+	user, err := sc.userRepository.GetUserByTelegramID(telegramID)
+	if err != nil {
+		return fmt.Errorf("error getting user %d for saved searches: %v", telegramID, err)
+	}
+	if user == nil {
+		// Пользователь удалил профиль - уведомлять не о ком
+		return nil
+	}
+
+	matches, err := sc.savedSearchRepository.FindMatchingSavedSearches(user, latitude, longitude)
+	if err != nil {
+		return fmt.Errorf("error finding matching saved searches: %v", err)
+	}
+
+	// Владелец может получить совпадение сразу по нескольким своим поискам - уведомляем один раз
+	notified := make(map[int64]bool)
+	for _, match := range matches {
+		if notified[match.OwnerTelegramID] {
+			continue
+		}
+		notified[match.OwnerTelegramID] = true
+
+		if !sc.allowNotification(match.OwnerTelegramID, telegramID) {
+			continue
+		}
+		distanceKm := geo.HaversineKm(match.Latitude, match.Longitude, latitude, longitude)
+		sc.notify(match.OwnerTelegramID, user, distanceKm)
+	}
+	return nil
+}
+
+// allowNotification ограничивает уведомления: владельцу - не чаще раза в cooldown,
+// об одном и том же пользователе - не чаще раза в repeatAfter
+func (sc *SavedSearchConsumer) allowNotification(ownerID, candidateID int64) bool {
+	ownerKey := fmt.Sprintf("saved_search_notify:%d", ownerID)
+	allowed, err := sc.redisClient.TryAcquire(ownerKey, sc.cooldown)
+	if err != nil {
+		log.Printf("Error checking saved search cooldown: %v", err)
+		return false
+	}
+	if !allowed {
+		return false
+	}
+
+	pairKey := fmt.Sprintf("saved_search_seen:%d:%d", ownerID, candidateID)
+	fresh, err := sc.redisClient.TryAcquire(pairKey, sc.repeatAfter)
+	if err != nil || !fresh {
+		// Уведомление не отправлено - не тратим на него интервал владельца
+		if err := sc.redisClient.Release(ownerKey); err != nil {
+			log.Printf("Error releasing saved search cooldown: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// notify отправляет владельцу сохраненного поиска карточку найденного пользователя
+func (sc *SavedSearchConsumer) notify(ownerID int64, user *repository.User, distanceKm float64) {
+	photo, err := sc.userRepository.GetUserPhoto(user.TelegramID)
+	if err == nil && photo != "" {
+		sc.bot.Send(tgbotapi.NewPhoto(ownerID, tgbotapi.FileID(photo)))
+	}
+
+	text := fmt.Sprintf("🔔 Рядом появился пользователь по вашему сохраненному поиску:\nИмя: %s\nВозраст: %d\nПол: %s\nО себе: %s\nРасстояние: %s",
+		user.FirstName, user.Age, user.Gender, user.Bio, sc.privacy.FormatDistance(distanceKm))
+	msg := tgbotapi.NewMessage(ownerID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Предложить пообщаться", fmt.Sprintf("connect_%d", user.TelegramID)),
+			tgbotapi.NewInlineKeyboardButtonData("❤️ Нравится", fmt.Sprintf("like_%d", user.TelegramID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Заблокировать", fmt.Sprintf("block_%d", user.TelegramID)),
+		),
+	)
+	sc.bot.Send(msg)
+}
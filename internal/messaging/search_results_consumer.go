@@ -0,0 +1,94 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"geo_match_bot/internal/cache"
+	"geo_match_bot/internal/carousel"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/search"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SearchResultsConsumer читает результаты поиска от воркеров и показывает их пользователям.
+// Показываются только результаты последнего запроса пользователя: ответы на запросы,
+// которые он уже повторил, пропускаются.
+type SearchResultsConsumer struct {
+	pool        *WorkerPool
+	bot         *tgbotapi.BotAPI   // Telegram Bot для отправки сообщений
+	redisClient *cache.RedisClient // ID последнего запроса поиска пользователя
+	carousel    *carousel.Carousel // Показ результатов поиска
+	codec       Codec              // Разбор событий
+	deadLetters *DeadLetterQueue   // Куда отправлять события, которые не удалось разобрать
+}
+
+func NewSearchResultsConsumer(pool *WorkerPool, bot *tgbotapi.BotAPI, redisClient *cache.RedisClient, resultsCarousel *carousel.Carousel, codec Codec, deadLetters *DeadLetterQueue) *SearchResultsConsumer {
+	return &SearchResultsConsumer{
+		pool:        pool,
+		bot:         bot,
+		redisClient: redisClient,
+		carousel:    resultsCarousel,
+		codec:       codec,
+		deadLetters: deadLetters,
+	}
+}
+
+// Run подписывается на SearchResultsTopic и показывает результаты. Блокирует вызывающую горутину до отмены ctx.
+func (c *SearchResultsConsumer) Run(ctx context.Context) error {
+	return c.pool.Run(ctx, SearchResultsTopic, c.process)
+}
+
+func (c *SearchResultsConsumer) process(msg *Message) {
+	if c.deadLetters.ReplayedForOther(msg) {
+		return
+	}
+
+	_, event, err := c.codec.Decode(msg)
+	if err != nil {
+		log.Printf("Skipping event %q: %v", msg.Value, err)
+		c.deadLetters.Send(msg, err, 1)
+		return
+	}
+	results, ok := event.(SearchResultsEvent)
+	if !ok {
+		return
+	}
+
+	// Запросы старых отправителей идут без ID - их результаты показываем всегда
+	if results.RequestID != "" {
+		current, err := c.redisClient.TakeSearchRequest(results.TelegramID, results.RequestID)
+		if err != nil {
+			log.Printf("Error checking search request: %v", err)
+		} else if !current {
+			log.Printf("Skipping results of outdated search request %s for %d", results.RequestID, results.TelegramID)
+			return
+		}
+	}
+
+	if results.Failed {
+		c.bot.Send(tgbotapi.NewMessage(results.TelegramID, "Ошибка при поиске пользователей. Попробуйте позже."))
+		return
+	}
+
+	neighbors := make([]geo.Neighbor, 0, len(results.Results))
+	for _, result := range results.Results {
+		neighbors = append(neighbors, geo.Neighbor{UserID: result.TelegramID, DistanceKm: result.DistanceKm})
+	}
+	c.SendSearchResults(results.TelegramID, neighbors, results.RadiusKm)
+}
+
+// SendSearchResults отправляет результаты поиска пользователю
+func (c *SearchResultsConsumer) SendSearchResults(telegramID int64, nearbyUsers []geo.Neighbor, radiusKm float64) {
+	if len(nearbyUsers) == 0 {
+		// Если пользователей не найдено, отправляем уведомление
+		c.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("К сожалению, в радиусе %s не найдено пользователей для общения. Попробуйте позже.", search.FormatRadius(radiusKm))))
+		return
+	}
+
+	c.bot.Send(tgbotapi.NewMessage(telegramID, fmt.Sprintf("Найдено пользователей: %d (радиус поиска: %s)", len(nearbyUsers), search.FormatRadius(radiusKm))))
+
+	// Показываем найденных пользователей одним сообщением, от ближайших к дальним
+	c.carousel.Show(telegramID, nearbyUsers)
+}
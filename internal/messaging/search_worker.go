@@ -0,0 +1,175 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"geo_match_bot/internal/geo"
+	"geo_match_bot/internal/repository"
+	"geo_match_bot/internal/search"
+	"log"
+)
+
+// SearchWorker обрабатывает события SearchTopic: ищет пользователей по запросам поиска
+// и отправляет результаты в SearchResultsTopic, а также ведет гео-индекс по событиям видимости.
+// С Telegram не работает: результаты показывает бот.
+type SearchWorker struct {
+	pool           *WorkerPool
+	geoIndex       geo.Index // Гео-индекс для поиска по геолокации
+	userRepository *repository.UserRepository
+	radiusPolicy   search.RadiusPolicy // Политика радиуса поиска
+
+	blockRepository *repository.BlockRepository
+	publisher       Publisher // Отправка результатов поиска
+	codec           Codec     // Разбор событий
+
+	retry       RetryPolicy      // Повторы при ошибках обработки
+	deadLetters *DeadLetterQueue // Куда отправлять события, которые не удалось обработать
+	guard       *EventGuard      // Повторные и устаревшие события
+}
+
+func NewSearchWorker(pool *WorkerPool, geoIndex geo.Index, userRepo *repository.UserRepository, radiusPolicy search.RadiusPolicy, blockRepo *repository.BlockRepository, publisher Publisher, codec Codec, retry RetryPolicy, deadLetters *DeadLetterQueue, guard *EventGuard) *SearchWorker {
+	return &SearchWorker{
+		pool:           pool,
+		geoIndex:       geoIndex,
+		userRepository: userRepo,
+		radiusPolicy:   radiusPolicy,
+
+		blockRepository: blockRepo,
+		publisher:       publisher,
+		codec:           codec,
+
+		retry:       retry,
+		deadLetters: deadLetters,
+		guard:       guard,
+	}
+}
+
+// Run подписывается на SearchTopic и обрабатывает события по их типу в пуле воркеров.
+// Блокирует вызывающую горутину до отмены ctx.
+func (w *SearchWorker) Run(ctx context.Context) error {
+	return w.pool.Run(ctx, SearchTopic, w.process)
+}
+
+// process обрабатывает сообщение с повторами; если обработать не удалось,
+// отправляет его в DeadLetterTopic, а для запроса поиска - сообщает боту о неудаче.
+// Уже обработанные события и изменения видимости старше примененных пропускаются.
+func (w *SearchWorker) process(msg *Message) {
+	if w.deadLetters.ReplayedForOther(msg) {
+		return
+	}
+
+	meta, event, err := w.codec.Decode(msg)
+	if err != nil {
+		log.Printf("Skipping event %q: %v", msg.Value, err)
+		w.deadLetters.Send(msg, err, 1)
+		return
+	}
+
+	if !w.guard.Claim(meta) {
+		log.Printf("Skipping %s event %s: already processed or in progress", meta.Type, meta.ID)
+		return
+	}
+	if userID, ok := stateChangeUser(event); ok && !w.guard.Fresh(meta, userID) {
+		log.Printf("Skipping outdated %s event %s for %d", meta.Type, meta.ID, userID)
+		w.guard.Done(meta)
+		return
+	}
+
+	attempts, err := w.retry.Do(func() error {
+		return w.route(event)
+	})
+	if err == nil {
+		w.guard.Done(meta)
+		return
+	}
+
+	log.Printf("Error handling %s event %q after %d attempts: %v", msg.EventType(), msg.Value, attempts, err)
+	w.guard.Release(meta)
+	w.deadLetters.Send(msg, err, attempts)
+	if request, ok := event.(UserSearchEvent); ok {
+		failed := SearchResultsEvent{RequestID: request.RequestID, TelegramID: request.TelegramID, Failed: true}
+		if err := w.publisher.Publish(failed); err != nil {
+			log.Printf("Error sending failed search results for %d: %v", request.TelegramID, err)
+		}
+	}
+}
+
+// route передает событие обработчику его типа
+func (w *SearchWorker) route(event Event) error {
+	switch e := event.(type) {
+	case UserSearchEvent:
+		return w.handleSearch(e)
+	case UserVisibilityEvent:
+		return w.handleVisibility(e.TelegramID, e.Latitude, e.Longitude)
+	case UserRemoveEvent:
+		return w.handleRemove(e.TelegramID)
+	default:
+		return fmt.Errorf("no handler for event type %q", event.EventType())
+	}
+}
+
+// handleSearch ищет пользователей рядом с точкой поиска и отправляет результаты боту
+func (w *SearchWorker) handleSearch(request UserSearchEvent) error {
+	telegramID := request.TelegramID
+
+	// Радиус, выбранный пользователем (0 - используем радиус по умолчанию)
+	preferredRadius, err := w.userRepository.GetUserSearchRadius(telegramID)
+	if err != nil {
+		log.Printf("Error getting search radius: %v", err)
+	}
+
+	// Заблокированные пользователи (в любую сторону) не попадают в поиск
+	blockedIDs, err := w.blockRepository.GetBlockedTelegramIDs(telegramID)
+	if err != nil {
+		return fmt.Errorf("error getting blocked users: %v", err)
+	}
+
+	// Ищем пользователей в гео-индексе, расширяя радиус при необходимости
+	nearbyUsers, radius, err := search.Expand(w.radiusPolicy, preferredRadius, func(radiusKm float64) ([]geo.Neighbor, error) {
+		return w.geoIndex.Nearby(geo.Query{
+			Latitude:       request.Latitude,
+			Longitude:      request.Longitude,
+			RadiusKm:       radiusKm,
+			Limit:          w.radiusPolicy.ResultLimit,
+			ExcludeUserID:  telegramID,
+			ExcludeUserIDs: blockedIDs,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error finding nearby users: %v", err)
+	}
+
+	// Отправляем найденных пользователей обратно в бот
+	results := make([]SearchResult, 0, len(nearbyUsers))
+	for _, neighbor := range nearbyUsers {
+		results = append(results, SearchResult{TelegramID: neighbor.UserID, DistanceKm: neighbor.DistanceKm})
+	}
+	err = w.publisher.Publish(SearchResultsEvent{
+		RequestID:  request.RequestID,
+		TelegramID: telegramID,
+		RadiusKm:   radius,
+		Results:    results,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending search results: %v", err)
+	}
+	return nil
+}
+
+// handleVisibility добавляет пользователя, включившего видимость, в гео-индекс.
+// Бот уже записал локацию сам; повторная запись идемпотентна и нужна,
+// если гео-индекс у воркера свой (например, в памяти).
+func (w *SearchWorker) handleVisibility(telegramID int64, latitude, longitude float64) error {
+	if err := w.geoIndex.Add(telegramID, latitude, longitude); err != nil {
+		return fmt.Errorf("error adding user location: %v", err)
+	}
+	return nil
+}
+
+// handleRemove убирает пользователя, выключившего видимость, из гео-индекса
+func (w *SearchWorker) handleRemove(telegramID int64) error {
+	if err := w.geoIndex.Remove(telegramID); err != nil {
+		return fmt.Errorf("error removing user location: %v", err)
+	}
+	return nil
+}
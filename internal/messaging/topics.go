@@ -0,0 +1,149 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"geo_match_bot/internal/config"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// adminTimeout - сколько ждать ответа брокера при проверке и создании топиков
+const adminTimeout = 30 * time.Second
+
+// TopicSpec - ожидаемые настройки топика
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Retention         time.Duration
+}
+
+// Topics возвращает настройки всех топиков приложения из конфигурации
+func Topics(cfg *config.Config) []TopicSpec {
+	spec := func(name string, retention time.Duration) TopicSpec {
+		return TopicSpec{
+			Name:              name,
+			Partitions:        cfg.KafkaTopicPartitions,
+			ReplicationFactor: cfg.KafkaReplicationFactor,
+			Retention:         retention,
+		}
+	}
+	return []TopicSpec{
+		spec(SearchTopic, cfg.KafkaTopicRetention),
+		spec(SearchResultsTopic, cfg.KafkaTopicRetention),
+		spec(DeadLetterTopic, cfg.KafkaDeadLetterRetention),
+	}
+}
+
+// EnsureTopics создает недостающие топики и проверяет настройки существующих.
+// Если существующий топик настроен иначе, возвращает ошибку с первым расхождением:
+// число партиций и фактор репликации приложение само не меняет.
+func EnsureTopics(broker string, specs []TopicSpec) error {
+	admin, err := kafka.NewAdminClient(&kafka.ConfigMap{"bootstrap.servers": broker})
+	if err != nil {
+		return fmt.Errorf("error creating Kafka admin client: %v", err)
+	}
+	defer admin.Close()
+
+	metadata, err := admin.GetMetadata(nil, true, int(adminTimeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("error getting Kafka metadata: %v", err)
+	}
+
+	var missing []kafka.TopicSpecification
+	var existing []TopicSpec
+	for _, spec := range specs {
+		topic, ok := metadata.Topics[spec.Name]
+		if !ok || topic.Error.Code() == kafka.ErrUnknownTopicOrPart {
+			missing = append(missing, kafka.TopicSpecification{
+				Topic:             spec.Name,
+				NumPartitions:     spec.Partitions,
+				ReplicationFactor: spec.ReplicationFactor,
+				Config:            map[string]string{"retention.ms": retentionMs(spec.Retention)},
+			})
+			continue
+		}
+		if err := checkTopicLayout(spec, topic); err != nil {
+			return err
+		}
+		existing = append(existing, spec)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	if len(missing) > 0 {
+		results, err := admin.CreateTopics(ctx, missing)
+		if err != nil {
+			return fmt.Errorf("error creating Kafka topics: %v", err)
+		}
+		for _, result := range results {
+			switch result.Error.Code() {
+			case kafka.ErrNoError:
+				log.Printf("Created Kafka topic %s", result.Topic)
+			case kafka.ErrTopicAlreadyExists:
+				// Топик создал другой процесс одновременно с нами
+			default:
+				return fmt.Errorf("error creating Kafka topic %s: %v", result.Topic, result.Error)
+			}
+		}
+	}
+
+	return checkTopicRetention(ctx, admin, existing)
+}
+
+// checkTopicLayout сравнивает число партиций и фактор репликации существующего топика с ожидаемыми
+func checkTopicLayout(spec TopicSpec, topic kafka.TopicMetadata) error {
+	if topic.Error.Code() != kafka.ErrNoError {
+		return fmt.Errorf("topic %s is unavailable: %v", spec.Name, topic.Error)
+	}
+	if len(topic.Partitions) != spec.Partitions {
+		return fmt.Errorf("topic %s has %d partitions, expected %d: change KAFKA_TOPIC_PARTITIONS or repartition the topic",
+			spec.Name, len(topic.Partitions), spec.Partitions)
+	}
+	for _, partition := range topic.Partitions {
+		if len(partition.Replicas) != spec.ReplicationFactor {
+			return fmt.Errorf("topic %s partition %d has replication factor %d, expected %d: change KAFKA_REPLICATION_FACTOR or reassign the topic",
+				spec.Name, partition.ID, len(partition.Replicas), spec.ReplicationFactor)
+		}
+	}
+	return nil
+}
+
+// checkTopicRetention сравнивает retention.ms существующих топиков с ожидаемым
+func checkTopicRetention(ctx context.Context, admin *kafka.AdminClient, specs []TopicSpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	resources := make([]kafka.ConfigResource, 0, len(specs))
+	expected := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		resources = append(resources, kafka.ConfigResource{Type: kafka.ResourceTopic, Name: spec.Name})
+		expected[spec.Name] = retentionMs(spec.Retention)
+	}
+
+	results, err := admin.DescribeConfigs(ctx, resources)
+	if err != nil {
+		return fmt.Errorf("error describing Kafka topics: %v", err)
+	}
+	for _, result := range results {
+		if result.Error.Code() != kafka.ErrNoError {
+			return fmt.Errorf("error describing Kafka topic %s: %v", result.Name, result.Error)
+		}
+		retention := result.Config["retention.ms"].Value
+		if retention != expected[result.Name] {
+			return fmt.Errorf("topic %s has retention.ms=%s, expected %s: change the retention setting or alter the topic config",
+				result.Name, retention, expected[result.Name])
+		}
+	}
+	return nil
+}
+
+func retentionMs(retention time.Duration) string {
+	return strconv.FormatInt(retention.Milliseconds(), 10)
+}
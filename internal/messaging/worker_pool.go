@@ -0,0 +1,204 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// readTimeout - как долго ReadMessage ждет сообщение, прежде чем пул проверит, не пора ли коммитить
+const readTimeout = 100 * time.Millisecond
+
+// WorkerPool читает сообщения одним циклом и обрабатывает их в нескольких воркерах.
+// Сообщения с одним ключом (одного пользователя) всегда попадают к одному воркеру
+// и обрабатываются по порядку. Позиция партиции коммитится, только когда обработаны
+// все прочитанные до нее сообщения.
+type WorkerPool struct {
+	subscriber     Subscriber
+	workers        int
+	commitInterval time.Duration // Как часто коммитить обработанные сообщения
+	queueSize      int           // Сколько сообщений может ждать у одного воркера
+}
+
+func NewWorkerPool(subscriber Subscriber, workers int, commitInterval time.Duration) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &WorkerPool{
+		subscriber:     subscriber,
+		workers:        workers,
+		commitInterval: commitInterval,
+		queueSize:      100,
+	}
+}
+
+// Run подписывается на топик и передает сообщения в handle, пока не отменен ctx.
+// handle сам повторяет обработку и отправляет необработанные сообщения в DeadLetterTopic:
+// после его возврата сообщение считается обработанным.
+// При отмене ctx или фатальной ошибке потребителя перестает читать, дожидается обработки
+// прочитанного, коммитит его и закрывает подписчика. Возвращает nil после отмены ctx.
+func (p *WorkerPool) Run(ctx context.Context, topic string, handle func(msg *Message)) error {
+	tracker := newOffsetTracker()
+	var inFlight sync.WaitGroup
+	var workers sync.WaitGroup
+
+	queues := make([]chan *Message, p.workers)
+	for i := range queues {
+		queues[i] = make(chan *Message, p.queueSize)
+		workers.Add(1)
+		go func(queue <-chan *Message) {
+			defer workers.Done()
+			for msg := range queue {
+				handle(msg)
+				tracker.finish(msg)
+				inFlight.Done()
+			}
+		}(queues[i])
+	}
+
+	commit := func() {
+		offsets := tracker.ready()
+		if len(offsets) == 0 {
+			return
+		}
+		if err := p.subscriber.Commit(offsets); err != nil {
+			log.Printf("Error committing offsets %v: %v", offsets, err)
+		}
+	}
+
+	// Перед отзывом партиций дожидаемся обработки прочитанного и коммитим его
+	onRevoke := func() {
+		inFlight.Wait()
+		commit()
+		tracker.reset()
+	}
+
+	// Остановка: воркеры дорабатывают свои очереди, обработанное коммитится
+	stop := func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		workers.Wait()
+		commit()
+		if err := p.subscriber.Close(); err != nil {
+			log.Printf("Error closing subscriber: %v", err)
+		}
+	}
+
+	if err := p.subscriber.Subscribe(topic, onRevoke); err != nil {
+		stop()
+		return fmt.Errorf("error subscribing to %s: %v", topic, err)
+	}
+
+	lastCommit := time.Now()
+	for ctx.Err() == nil {
+		msg, err := p.subscriber.ReadMessage(readTimeout)
+		switch {
+		case err == nil:
+			tracker.start(msg)
+			inFlight.Add(1)
+			queues[p.worker(msg.Key)] <- msg
+		case errors.Is(err, ErrFatal):
+			stop()
+			return fmt.Errorf("fatal consumer error: %v", err)
+		case !errors.Is(err, ErrTimeout):
+			log.Printf("Error reading message: %v", err)
+		}
+
+		if time.Since(lastCommit) >= p.commitInterval {
+			commit()
+			lastCommit = time.Now()
+		}
+	}
+
+	stop()
+	return nil
+}
+
+// worker выбирает воркер по ключу сообщения
+func (p *WorkerPool) worker(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(p.workers))
+}
+
+// offsetTracker отслеживает, какие прочитанные сообщения уже обработаны, и вычисляет
+// для каждой партиции позицию, до которой все сообщения обработаны
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionID]*partitionOffsets
+}
+
+type partitionID struct {
+	topic     string
+	partition int32
+}
+
+type partitionOffsets struct {
+	inFlight []int64        // Позиции прочитанных сообщений в порядке чтения
+	done     map[int64]bool // Обработанные сообщения, которые еще нельзя закоммитить
+	next     int64          // Позиция для коммита (-1 - коммитить нечего)
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionID]*partitionOffsets)}
+}
+
+func (t *offsetTracker) start(msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := partitionID{topic: msg.Topic, partition: msg.Partition}
+	offsets, ok := t.partitions[id]
+	if !ok {
+		offsets = &partitionOffsets{done: make(map[int64]bool), next: -1}
+		t.partitions[id] = offsets
+	}
+	offsets.inFlight = append(offsets.inFlight, msg.Offset)
+}
+
+func (t *offsetTracker) finish(msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	offsets, ok := t.partitions[partitionID{topic: msg.Topic, partition: msg.Partition}]
+	if !ok {
+		return
+	}
+	offsets.done[msg.Offset] = true
+
+	// Сдвигаем позицию коммита, пока сообщения обработаны подряд
+	for len(offsets.inFlight) > 0 && offsets.done[offsets.inFlight[0]] {
+		delete(offsets.done, offsets.inFlight[0])
+		offsets.next = offsets.inFlight[0] + 1
+		offsets.inFlight = offsets.inFlight[1:]
+	}
+}
+
+// ready возвращает позиции, которые можно закоммитить, и забывает их
+func (t *offsetTracker) ready() []Offset {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var offsets []Offset
+	for id, partition := range t.partitions {
+		if partition.next < 0 {
+			continue
+		}
+		offsets = append(offsets, Offset{Topic: id.topic, Partition: id.partition, Offset: partition.next})
+		partition.next = -1
+	}
+	return offsets
+}
+
+// reset забывает все партиции: после перебалансировки их могут назначить заново с другой позиции
+func (t *offsetTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partitions = make(map[partitionID]*partitionOffsets)
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table users add column search_radius_km double precision;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table users drop column search_radius_km;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied.
+
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- История точных координат переезжает в отдельную таблицу,
+-- а locations хранит одну текущую локацию на пользователя для гео-поиска
+CREATE TABLE IF NOT EXISTS location_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    latitude DECIMAL(9, 6) NOT NULL,
+    longitude DECIMAL(9, 6) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_location_history_created_at ON location_history (created_at);
+
+-- Старые точные координаты не переносятся: история хранится только при явно заданном сроке,
+-- а гео-индекс заполнится заново из новых обновлений локаций
+DELETE FROM locations;
+
+ALTER TABLE locations ADD COLUMN geog geography(Point, 4326) NOT NULL;
+ALTER TABLE locations ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_locations_user_id ON locations (user_id);
+CREATE INDEX IF NOT EXISTS idx_locations_geog ON locations USING GIST (geog);
+CREATE INDEX IF NOT EXISTS idx_locations_updated_at ON locations (updated_at);
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back.
+
+DROP INDEX IF EXISTS idx_locations_updated_at;
+DROP INDEX IF EXISTS idx_locations_geog;
+DROP INDEX IF EXISTS idx_locations_user_id;
+
+ALTER TABLE locations DROP COLUMN updated_at;
+ALTER TABLE locations DROP COLUMN geog;
+
+DELETE FROM locations;
+
+INSERT INTO locations (user_id, latitude, longitude, created_at)
+SELECT user_id, latitude, longitude, created_at FROM location_history;
+
+DROP TABLE IF EXISTS location_history;
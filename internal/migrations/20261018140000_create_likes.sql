@@ -0,0 +1,21 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied.
+
+CREATE TABLE IF NOT EXISTS likes (
+    liker_id INTEGER NOT NULL,  -- Кто оценил
+    liked_id INTEGER NOT NULL,  -- Кого оценили
+    is_like BOOLEAN NOT NULL,   -- TRUE - нравится, FALSE - пропустить
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (liker_id, liked_id),
+    FOREIGN KEY (liker_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (liked_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Для списка "кому я понравился"
+CREATE INDEX IF NOT EXISTS idx_likes_liked_id ON likes (liked_id) WHERE is_like;
+
+-- +goose Down
+-- SQL in section 'Down' is executed when this migration is rolled back.
+
+DROP TABLE IF EXISTS likes;
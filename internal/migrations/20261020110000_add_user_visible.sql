@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Видимость пользователя: пишется в одной транзакции с событием о ее изменении (outbox)
+alter table users add column visible boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table users drop column visible;
+-- +goose StatementEnd
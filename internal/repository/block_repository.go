@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// BlockRepository хранит блокировки пользователей (таблица blocks).
+// Блокировка действует в обе стороны: заблокированные пары не видят друг друга.
+type BlockRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+// Конструктор для создания репозитория блокировок
+func NewBlockRepository(db *sql.DB) *BlockRepository {
+	return &BlockRepository{
+		db:      db,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Метод для блокировки пользователя. Повторная блокировка ничего не меняет.
+func (r *BlockRepository) Block(blockerTelegramID, blockedTelegramID int64) error {
+	query := r.builder.Insert("blocks").
+		Columns("blocker_id", "blocked_id").
+		Values(
+			sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", blockerTelegramID),
+			sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", blockedTelegramID),
+		).
+		Suffix("ON CONFLICT DO NOTHING")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для снятия блокировки, поставленной пользователем
+func (r *BlockRepository) Unblock(blockerTelegramID, blockedTelegramID int64) error {
+	query := r.builder.Delete("blocks").
+		Where(sq.Expr("blocker_id = (SELECT id FROM users WHERE telegram_id = ?)", blockerTelegramID)).
+		Where(sq.Expr("blocked_id = (SELECT id FROM users WHERE telegram_id = ?)", blockedTelegramID))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для проверки, заблокировал ли кто-то из двух пользователей другого
+func (r *BlockRepository) IsBlocked(telegramID1, telegramID2 int64) (bool, error) {
+	query := r.builder.Select("1").
+		From("blocks b").
+		Join("users u1 ON u1.id = b.blocker_id").
+		Join("users u2 ON u2.id = b.blocked_id").
+		Where(sq.Or{
+			sq.Eq{"u1.telegram_id": telegramID1, "u2.telegram_id": telegramID2},
+			sq.Eq{"u1.telegram_id": telegramID2, "u2.telegram_id": telegramID1},
+		}).
+		Limit(1)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("error building query: %v", err)
+	}
+
+	var exists int
+	err = r.db.QueryRow(sqlQuery, args...).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Метод для получения telegram_id всех, с кем у пользователя есть блокировка в любую сторону
+func (r *BlockRepository) GetBlockedTelegramIDs(telegramID int64) ([]int64, error) {
+	query := r.builder.Select("other.telegram_id").
+		From("blocks b").
+		Join("users me ON me.id = b.blocker_id OR me.id = b.blocked_id").
+		Join("users other ON other.id = CASE WHEN b.blocker_id = me.id THEN b.blocked_id ELSE b.blocker_id END").
+		Where(sq.Eq{"me.telegram_id": telegramID})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var telegramIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		telegramIDs = append(telegramIDs, id)
+	}
+	return telegramIDs, rows.Err()
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// LikeRepository хранит оценки пользователей в режиме взаимных симпатий (таблица likes).
+// На пару пользователей хранится одна оценка: "нравится" или "пропустить".
+type LikeRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+// Конструктор для создания репозитория оценок
+func NewLikeRepository(db *sql.DB) *LikeRepository {
+	return &LikeRepository{
+		db:      db,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Метод для сохранения оценки. Повторная такая же оценка ничего не меняет:
+// возвращает false, если оценка уже была записана раньше.
+func (r *LikeRepository) RecordLike(likerTelegramID, likedTelegramID int64, isLike bool) (bool, error) {
+	query := r.builder.Insert("likes").
+		Columns("liker_id", "liked_id", "is_like").
+		Values(
+			sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", likerTelegramID),
+			sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", likedTelegramID),
+			isLike,
+		).
+		Suffix("ON CONFLICT (liker_id, liked_id) DO UPDATE SET is_like = EXCLUDED.is_like, updated_at = CURRENT_TIMESTAMP WHERE likes.is_like <> EXCLUDED.is_like")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("error building query: %v", err)
+	}
+
+	result, err := r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return false, fmt.Errorf("error executing query: %v", err)
+	}
+
+	changed, err := result.RowsAffected()
+	return changed > 0, err
+}
+
+// Метод для проверки взаимной симпатии: оба пользователя отметили "нравится"
+func (r *LikeRepository) IsMutualLike(telegramID1, telegramID2 int64) (bool, error) {
+	query := r.builder.Select("COUNT(*)").
+		From("likes l").
+		Join("users u1 ON u1.id = l.liker_id").
+		Join("users u2 ON u2.id = l.liked_id").
+		Where(sq.Eq{"l.is_like": true}).
+		Where(sq.Or{
+			sq.Eq{"u1.telegram_id": telegramID1, "u2.telegram_id": telegramID2},
+			sq.Eq{"u1.telegram_id": telegramID2, "u2.telegram_id": telegramID1},
+		})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("error building query: %v", err)
+	}
+
+	var count int
+	err = r.db.QueryRow(sqlQuery, args...).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 2, nil
+}
+
+// Метод для получения пользователей, которым понравился пользователь и которых он еще не оценил.
+// Пользователи, с которыми есть блокировка, не возвращаются.
+func (r *LikeRepository) GetLikers(telegramID int64, limit uint64) ([]User, error) {
+	query := r.builder.Select("u.id", "u.title_name", "u.telegram_id", "u.username", "u.first_name", "u.last_name", "u.gender", "u.age", "u.bio").
+		From("likes l").
+		Join("users u ON u.id = l.liker_id").
+		Join("users me ON me.id = l.liked_id").
+		Where(sq.Eq{"me.telegram_id": telegramID, "l.is_like": true}).
+		Where("NOT EXISTS (SELECT 1 FROM likes answer WHERE answer.liker_id = me.id AND answer.liked_id = u.id)").
+		Where("NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = me.id AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = me.id))").
+		OrderBy("l.updated_at DESC").
+		Limit(limit)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.TitleName, &user.TelegramID, &user.Username, &user.FirstName, &user.LastName, &user.Gender, &user.Age, &user.Bio); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// LocationRepository хранит историю точных координат пользователей (таблица location_history).
+// Записи хранятся ограниченное время и удаляются очисткой по политике хранения.
+type LocationRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+// Конструктор для создания репозитория локаций
+func NewLocationRepository(db *sql.DB) *LocationRepository {
+	return &LocationRepository{
+		db:      db,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Метод для сохранения точной локации пользователя
+func (r *LocationRepository) SaveRawLocation(telegramID int64, latitude, longitude float64) error {
+	query := r.builder.Insert("location_history").
+		Columns("user_id", "latitude", "longitude").
+		Values(sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", telegramID), latitude, longitude)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для удаления локаций, сохраненных раньше заданного момента
+func (r *LocationRepository) DeleteLocationsOlderThan(cutoff time.Time) (int64, error) {
+	query := r.builder.Delete("location_history").
+		Where(sq.Lt{"created_at": cutoff})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("error building query: %v", err)
+	}
+
+	result, err := r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+
+	return result.RowsAffected()
+}
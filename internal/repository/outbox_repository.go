@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// OutboxMessage - сообщение, ожидающее отправки в Kafka
+type OutboxMessage struct {
+	ID       int64
+	EventID  string
+	Topic    string
+	Key      string
+	Value    []byte
+	Headers  map[string]string
+	Attempts int
+}
+
+// OutboxRepository - таблица outbox: сообщения пишутся в транзакции с изменениями данных,
+// воркер забирает их на время аренды, отправляет и отмечает отправленными
+type OutboxRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+// Конструктор для создания репозитория outbox
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db:      db,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Метод для записи сообщения вне транзакции
+func (r *OutboxRepository) Enqueue(msg OutboxMessage) error {
+	return r.enqueue(r.db, msg)
+}
+
+// Метод для записи сообщения в транзакции вызывающего: сообщение будет отправлено,
+// только если транзакция зафиксирована
+func (r *OutboxRepository) EnqueueTx(tx *sql.Tx, msg OutboxMessage) error {
+	return r.enqueue(tx, msg)
+}
+
+func (r *OutboxRepository) enqueue(runner sq.BaseRunner, msg OutboxMessage) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := r.builder.Insert("outbox").
+		Columns("event_id", "topic", "key", "value", "headers").
+		Values(nullString(msg.EventID), msg.Topic, msg.Key, msg.Value, headers).
+		// Повторная запись того же события ничего не меняет
+		Suffix("ON CONFLICT (event_id) DO NOTHING")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = runner.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// claimableBatch выбирает первые по порядку неотправленные сообщения, которые никто не забрал
+// (или аренда истекла). Сообщения, которые сейчас забирает другой воркер, пропускаются (SKIP LOCKED).
+const claimableBatch = `WITH batch AS (
+	SELECT id, key FROM outbox
+	WHERE sent_at IS NULL AND failed_at IS NULL
+		AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+	ORDER BY id
+	LIMIT ?
+	FOR UPDATE SKIP LOCKED
+)`
+
+// claimablePending оставляет из пачки только сообщения, перед которыми нет более раннего
+// неотправленного сообщения того же ключа вне пачки: так события одного пользователя не обгоняют
+// друг друга. Более раннее сообщение вне пачки либо арендовано другим воркером, либо
+// забирается им прямо сейчас и пропущено SKIP LOCKED (его аренда еще не видна в READ COMMITTED).
+const claimablePending = `id IN (
+	SELECT b.id FROM batch b
+	WHERE NOT EXISTS (
+		SELECT 1 FROM outbox earlier
+		WHERE earlier.key = b.key AND earlier.id < b.id
+			AND earlier.sent_at IS NULL AND earlier.failed_at IS NULL
+			AND earlier.id NOT IN (SELECT id FROM batch)
+	)
+)`
+
+// Метод для получения неотправленных сообщений на время lease. Запрос фиксируется сразу,
+// а сообщения отправляются уже вне транзакции: если воркер упадет, после lease их заберет другой.
+// Сообщения возвращаются по порядку записи.
+func (r *OutboxRepository) ClaimPending(limit uint64, lease time.Duration) ([]OutboxMessage, error) {
+	query := r.builder.Update("outbox").
+		Prefix(claimableBatch, limit).
+		Set("locked_until", time.Now().Add(lease)).
+		Where(claimablePending).
+		Suffix("RETURNING id, COALESCE(event_id, ''), topic, key, value, headers, attempts")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		var headers []byte
+		if err := rows.Scan(&msg.ID, &msg.EventID, &msg.Topic, &msg.Key, &msg.Value, &headers, &msg.Attempts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+// Метод для отметки сообщения отправленным
+func (r *OutboxRepository) MarkSent(id int64) error {
+	return r.update(r.builder.Update("outbox").
+		Set("sent_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Set("locked_until", nil).
+		Where(sq.Eq{"id": id}))
+}
+
+// Метод для учета неудачной отправки. Сообщение возвращается в очередь, а после maxAttempts
+// попыток откладывается (failed_at), чтобы не задерживать следующие сообщения.
+// Возвращает true, если сообщение отложено.
+func (r *OutboxRepository) MarkFailed(id int64, sendErr error, attempts, maxAttempts int) (bool, error) {
+	attempts++
+	query := r.builder.Update("outbox").
+		Set("attempts", attempts).
+		Set("last_error", sendErr.Error()).
+		Set("locked_until", nil).
+		Where(sq.Eq{"id": id})
+
+	exhausted := maxAttempts > 0 && attempts >= maxAttempts
+	if exhausted {
+		query = query.Set("failed_at", sq.Expr("CURRENT_TIMESTAMP"))
+	}
+	return exhausted, r.update(query)
+}
+
+// Метод для возврата забранных, но не отправленных сообщений в очередь
+func (r *OutboxRepository) Release(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.update(r.builder.Update("outbox").
+		Set("locked_until", nil).
+		Where(sq.Eq{"id": ids}))
+}
+
+func (r *OutboxRepository) update(query sq.UpdateBuilder) error {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+	return nil
+}
+
+// Метод для удаления отправленных сообщений старше retention
+func (r *OutboxRepository) DeleteSent(retention time.Duration) (int64, error) {
+	query := r.builder.Delete("outbox").
+		Where(sq.NotEq{"sent_at": nil}).
+		Where(sq.Lt{"sent_at": time.Now().Add(-retention)})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("error building query: %v", err)
+	}
+
+	result, err := r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %v", err)
+	}
+	return result.RowsAffected()
+}
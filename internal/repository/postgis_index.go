@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"geo_match_bot/internal/geo"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// pointExpr - точка PostGIS из долготы и широты
+const pointExpr = "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+
+// PostGISIndex - гео-индекс пользователей в таблице locations (PostGIS, GiST-индекс по geog).
+// В таблице хранится одна текущая локация на пользователя.
+type PostGISIndex struct {
+	db           *sql.DB
+	builder      sq.StatementBuilderType
+	searchMaxAge time.Duration // Кандидаты с более старой локацией не попадают в поиск
+}
+
+// Конструктор для создания гео-индекса на PostGIS
+func NewPostGISIndex(db *sql.DB, searchMaxAge time.Duration) *PostGISIndex {
+	return &PostGISIndex{
+		db:           db,
+		builder:      sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		searchMaxAge: searchMaxAge,
+	}
+}
+
+// Метод для добавления или обновления локации пользователя
+func (r *PostGISIndex) Add(userID int64, latitude, longitude float64) error {
+	query := r.builder.Insert("locations").
+		Columns("user_id", "latitude", "longitude", "geog", "updated_at").
+		Values(
+			sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", userID),
+			latitude,
+			longitude,
+			sq.Expr(pointExpr, longitude, latitude),
+			sq.Expr("CURRENT_TIMESTAMP"),
+		).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, geog = EXCLUDED.geog, updated_at = EXCLUDED.updated_at")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для удаления локации пользователя
+func (r *PostGISIndex) Remove(userID int64) error {
+	query := r.builder.Delete("locations").
+		Where(sq.Expr("user_id = (SELECT id FROM users WHERE telegram_id = ?)", userID))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для поиска пользователей по кругу (ST_DWithin) или прямоугольнику (&&),
+// от ближайших к дальним (KNN-оператор <-> использует GiST-индекс)
+func (r *PostGISIndex) Nearby(q geo.Query) ([]geo.Neighbor, error) {
+	latitude, longitude := q.Latitude, q.Longitude
+	if q.FromUserID != 0 {
+		var err error
+		latitude, longitude, err = r.Position(q.FromUserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := r.builder.Select("u.telegram_id").
+		Column(sq.Expr("ST_Distance(l.geog, "+pointExpr+") / 1000", longitude, latitude)).
+		From("locations l").
+		Join("users u ON u.id = l.user_id").
+		OrderByClause("l.geog <-> "+pointExpr, longitude, latitude)
+
+	if q.IsBox() {
+		minLat, minLon, maxLat, maxLon := q.Bounds(latitude, longitude)
+		query = query.Where(sq.Expr("l.geog::geometry && ST_MakeEnvelope(?, ?, ?, ?, 4326)", minLon, minLat, maxLon, maxLat))
+	} else {
+		query = query.Where(sq.Expr("ST_DWithin(l.geog, "+pointExpr+", ?)", longitude, latitude, q.RadiusKm*1000))
+	}
+	if excluded := q.Excluded(); len(excluded) > 0 {
+		query = query.Where(sq.NotEq{"u.telegram_id": excluded})
+	}
+	if r.searchMaxAge > 0 {
+		query = query.Where(sq.GtOrEq{"l.updated_at": time.Now().Add(-r.searchMaxAge)})
+	}
+	if q.Limit > 0 {
+		query = query.Limit(uint64(q.Limit))
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var neighbors []geo.Neighbor
+	for rows.Next() {
+		var neighbor geo.Neighbor
+		if err := rows.Scan(&neighbor.UserID, &neighbor.DistanceKm); err != nil {
+			return nil, err
+		}
+		neighbors = append(neighbors, neighbor)
+	}
+
+	return neighbors, rows.Err()
+}
+
+// Метод для получения сохраненной локации пользователя
+func (r *PostGISIndex) Position(userID int64) (float64, float64, error) {
+	query := r.builder.Select("l.latitude::float8", "l.longitude::float8").
+		From("locations l").
+		Join("users u ON u.id = l.user_id").
+		Where(sq.Eq{"u.telegram_id": userID})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error building query: %v", err)
+	}
+
+	var latitude, longitude float64
+	err = r.db.QueryRow(sqlQuery, args...).Scan(&latitude, &longitude)
+	if err == sql.ErrNoRows {
+		return 0, 0, fmt.Errorf("location of user %d not found", userID)
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	return latitude, longitude, nil
+}
+
+// Метод для удаления локаций, не обновлявшихся дольше maxAge
+func (r *PostGISIndex) RemoveStale(maxAge time.Duration) ([]int64, error) {
+	query := r.builder.Delete("locations").
+		Where(sq.Lt{"updated_at": time.Now().Add(-maxAge)}).
+		Suffix("RETURNING (SELECT telegram_id FROM users WHERE users.id = locations.user_id)")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var removed []int64
+	for rows.Next() {
+		var telegramID int64
+		if err := rows.Scan(&telegramID); err != nil {
+			return nil, err
+		}
+		removed = append(removed, telegramID)
+	}
+
+	return removed, rows.Err()
+}
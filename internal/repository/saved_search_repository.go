@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SavedSearch - сохраненный поиск: центр, радиус и фильтры по полу и возрасту
+type SavedSearch struct {
+	ID        int
+	RadiusKm  float64
+	Gender    string // Пустая строка - любой пол
+	AgeMin    int    // 0 - без ограничения
+	AgeMax    int    // 0 - без ограничения
+	Latitude  float64
+	Longitude float64
+}
+
+// SavedSearchMatch - сохраненный поиск, которому подошел пользователь, и его владелец
+type SavedSearchMatch struct {
+	SearchID        int
+	OwnerTelegramID int64
+	Latitude        float64
+	Longitude       float64
+}
+
+type SavedSearchRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+// Конструктор для создания репозитория сохраненных поисков
+func NewSavedSearchRepository(db *sql.DB) *SavedSearchRepository {
+	return &SavedSearchRepository{
+		db:      db,
+		builder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Метод для сохранения поиска пользователя
+func (r *SavedSearchRepository) CreateSavedSearch(telegramID int64, s SavedSearch) error {
+	query := r.builder.Insert("saved_searches").
+		Columns("user_id", "radius_km", "gender", "age_min", "age_max", "latitude", "longitude", "geog").
+		Values(
+			sq.Expr("(SELECT id FROM users WHERE telegram_id = ?)", telegramID),
+			s.RadiusKm,
+			nullString(s.Gender),
+			nullInt(s.AgeMin),
+			nullInt(s.AgeMax),
+			s.Latitude,
+			s.Longitude,
+			sq.Expr(pointExpr, s.Longitude, s.Latitude),
+		)
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для получения сохраненных поисков пользователя
+func (r *SavedSearchRepository) GetSavedSearches(telegramID int64) ([]SavedSearch, error) {
+	query := r.builder.Select("s.id", "s.radius_km", "COALESCE(s.gender, '')", "COALESCE(s.age_min, 0)", "COALESCE(s.age_max, 0)", "s.latitude", "s.longitude").
+		From("saved_searches s").
+		Join("users u ON u.id = s.user_id").
+		Where(sq.Eq{"u.telegram_id": telegramID}).
+		OrderBy("s.id")
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		var s SavedSearch
+		if err := rows.Scan(&s.ID, &s.RadiusKm, &s.Gender, &s.AgeMin, &s.AgeMax, &s.Latitude, &s.Longitude); err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+// Метод для удаления сохраненного поиска (только своего)
+func (r *SavedSearchRepository) DeleteSavedSearch(telegramID int64, searchID int) (bool, error) {
+	query := r.builder.Delete("saved_searches").
+		Where(sq.Eq{"id": searchID}).
+		Where(sq.Expr("user_id = (SELECT id FROM users WHERE telegram_id = ?)", telegramID))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("error building query: %v", err)
+	}
+
+	result, err := r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return false, fmt.Errorf("error executing query: %v", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// Метод для поиска сохраненных поисков, под которые подходит пользователь в заданной точке.
+// Учитываются радиус поиска, пол и возрастной диапазон; собственные поиски пользователя
+// и поиски тех, с кем у него есть блокировка, пропускаются.
+func (r *SavedSearchRepository) FindMatchingSavedSearches(user *User, latitude, longitude float64) ([]SavedSearchMatch, error) {
+	query := r.builder.Select("s.id", "u.telegram_id", "s.latitude", "s.longitude").
+		From("saved_searches s").
+		Join("users u ON u.id = s.user_id").
+		Where(sq.Expr("ST_DWithin(s.geog, "+pointExpr+", s.radius_km * 1000)", longitude, latitude)).
+		Where(sq.NotEq{"u.telegram_id": user.TelegramID}).
+		Where(sq.Or{sq.Eq{"s.gender": nil}, sq.Expr("lower(s.gender) = lower(?)", user.Gender)}).
+		Where(sq.Or{sq.Eq{"s.age_min": nil}, sq.LtOrEq{"s.age_min": user.Age}}).
+		Where(sq.Or{sq.Eq{"s.age_max": nil}, sq.GtOrEq{"s.age_max": user.Age}}).
+		Where(sq.Expr("NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = u.id AND b.blocked_id = ?) OR (b.blocker_id = ? AND b.blocked_id = u.id))", user.ID, user.ID))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %v", err)
+	}
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var matches []SavedSearchMatch
+	for rows.Next() {
+		var m SavedSearchMatch
+		if err := rows.Scan(&m.SearchID, &m.OwnerTelegramID, &m.Latitude, &m.Longitude); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// nullString превращает пустую строку в NULL
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullInt превращает ноль в NULL
+func nullInt(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
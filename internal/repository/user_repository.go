@@ -274,3 +274,86 @@ func (r *UserRepository) GetUserPhotoByID(userID string) (string, error) {
 
 	return photoURL, nil
 }
+
+// Метод для сохранения предпочтительного радиуса поиска пользователя
+func (r *UserRepository) UpdateUserSearchRadius(telegramID int64, radiusKm float64) error {
+	query := r.builder.Update("users").
+		Set("search_radius_km", radiusKm).
+		Where(sq.Eq{"telegram_id": telegramID})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = r.db.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для получения радиуса поиска пользователя (0, если пользователь его не выбирал)
+func (r *UserRepository) GetUserSearchRadius(telegramID int64) (float64, error) {
+	query := r.builder.Select("search_radius_km").
+		From("users").
+		Where(sq.Eq{"telegram_id": telegramID})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("error building query: %v", err)
+	}
+
+	var radius sql.NullFloat64
+	err = r.db.QueryRow(sqlQuery, args...).Scan(&radius)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return radius.Float64, nil
+}
+
+// Метод для сохранения видимости пользователя в транзакции вызывающего
+// (вместе с событием о ней в outbox)
+func (r *UserRepository) SetUserVisibleTx(tx *sql.Tx, telegramID int64, visible bool) error {
+	query := r.builder.Update("users").
+		Set("visible", visible).
+		Where(sq.Eq{"telegram_id": telegramID})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building query: %v", err)
+	}
+
+	_, err = tx.Exec(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("error executing query: %v", err)
+	}
+
+	return nil
+}
+
+// Метод для получения видимости пользователя (false, если пользователя нет)
+func (r *UserRepository) GetUserVisible(telegramID int64) (bool, error) {
+	query := r.builder.Select("visible").
+		From("users").
+		Where(sq.Eq{"telegram_id": telegramID})
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("error building query: %v", err)
+	}
+
+	var visible bool
+	err = r.db.QueryRow(sqlQuery, args...).Scan(&visible)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"fmt"
+	"geo_match_bot/internal/config"
+	"strconv"
+)
+
+// RadiusOptions - варианты радиуса (в км), которые пользователь может выбрать кнопками
+var RadiusOptions = []float64{1, 3, 5, 10, 25, 50}
+
+// RadiusPolicy описывает, с какого радиуса начинать поиск, как его расширять
+// и сколько кандидатов возвращать
+type RadiusPolicy struct {
+	DefaultKm   float64
+	StepKm      float64
+	MaxKm       float64
+	MinResults  int
+	ResultLimit int
+}
+
+// NewRadiusPolicy создает политику радиуса из конфигурации
+func NewRadiusPolicy(cfg *config.Config) RadiusPolicy {
+	return RadiusPolicy{
+		DefaultKm:   cfg.SearchDefaultRadiusKm,
+		StepKm:      cfg.SearchRadiusStepKm,
+		MaxKm:       cfg.SearchMaxRadiusKm,
+		MinResults:  cfg.SearchMinResults,
+		ResultLimit: cfg.SearchResultLimit,
+	}
+}
+
+// Start возвращает начальный радиус: выбранный пользователем или радиус по умолчанию,
+// но не больше максимального
+func (p RadiusPolicy) Start(preferredKm float64) float64 {
+	radius := preferredKm
+	if radius <= 0 {
+		radius = p.DefaultKm
+	}
+	if p.MaxKm > 0 && radius > p.MaxKm {
+		radius = p.MaxKm
+	}
+	return radius
+}
+
+// Options возвращает варианты радиуса, которые можно выбрать при текущем максимальном радиусе.
+// Сам максимальный радиус тоже предлагается, даже если его нет в RadiusOptions.
+func (p RadiusPolicy) Options() []float64 {
+	if p.MaxKm <= 0 {
+		return RadiusOptions
+	}
+
+	options := make([]float64, 0, len(RadiusOptions)+1)
+	hasMax := false
+	for _, option := range RadiusOptions {
+		if option > p.MaxKm {
+			continue
+		}
+		if option == p.MaxKm {
+			hasMax = true
+		}
+		options = append(options, option)
+	}
+	if !hasMax {
+		options = append(options, p.MaxKm)
+	}
+	return options
+}
+
+// Expand выполняет поиск, начиная с предпочтительного радиуса, и расширяет его шагами,
+// пока не найдено MinResults кандидатов или не достигнут максимальный радиус.
+// Возвращает найденных кандидатов и радиус, с которым они были найдены.
+func Expand[T any](p RadiusPolicy, preferredKm float64, find func(radiusKm float64) ([]T, error)) ([]T, float64, error) {
+	radius := p.Start(preferredKm)
+	for {
+		results, err := find(radius)
+		if err != nil {
+			return nil, radius, err
+		}
+
+		if len(results) >= p.MinResults || p.StepKm <= 0 || radius >= p.MaxKm {
+			return results, radius, nil
+		}
+
+		radius += p.StepKm
+		if radius > p.MaxKm {
+			radius = p.MaxKm
+		}
+	}
+}
+
+// FormatRadius форматирует радиус для показа пользователю
+func FormatRadius(radiusKm float64) string {
+	return fmt.Sprintf("%s км", strconv.FormatFloat(radiusKm, 'f', -1, 64))
+}